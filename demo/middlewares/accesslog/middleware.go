@@ -30,6 +30,8 @@ func (b MiddlewareBuilder) Build() web.Middleware {
 					Route:      ctx.MatchedRoute,
 					HTTPMethod: ctx.Req.Method,
 					Path:       ctx.Req.URL.Path,
+					ClientIP:   ctx.ClientIP(),
+					RequestID:  ctx.RequestID(),
 				}
 				val, _ := json.Marshal(l)
 				b.logFunc(string(val))
@@ -45,4 +47,6 @@ type accessLog struct {
 	Route      string `json:"route"`
 	HTTPMethod string `json:"http_method"`
 	Path       string `json:"path"`
+	ClientIP   string `json:"client_ip"`
+	RequestID  string `json:"request_id,omitempty"`
 }
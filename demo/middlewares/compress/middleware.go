@@ -0,0 +1,271 @@
+package compress
+
+import (
+	"bookstore/demo/web"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MiddlewareBuilder 压缩响应的中间件
+// 只会压缩缓存在 RespData 里面的数据，直接写 Resp 的流式响应不会被压缩
+type MiddlewareBuilder struct {
+	// 小于这个长度的响应不压缩，压缩小数据得不偿失
+	minLength int
+	// 可压缩的 Content-Type 前缀
+	contentTypes []string
+	// 服务端支持的编码，按照优先级排列
+	encodings []string
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		minLength: 1024,
+		contentTypes: []string{
+			"text/",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"image/svg+xml",
+		},
+		encodings: []string{"br", "zstd", "gzip"},
+	}
+}
+
+// MinLength 设置压缩的最小长度
+func (b *MiddlewareBuilder) MinLength(n int) *MiddlewareBuilder {
+	b.minLength = n
+	return b
+}
+
+// ContentTypes 设置可压缩的 Content-Type 前缀，会覆盖默认值
+func (b *MiddlewareBuilder) ContentTypes(types ...string) *MiddlewareBuilder {
+	b.contentTypes = types
+	return b
+}
+
+// Encodings 设置服务端支持的编码和优先级，可选 br, zstd, gzip
+// 客户端的 q 值相同的时候，排在前面的优先
+func (b *MiddlewareBuilder) Encodings(encodings ...string) *MiddlewareBuilder {
+	b.encodings = encodings
+	return b
+}
+
+func (b MiddlewareBuilder) Build() web.Middleware {
+	encoders := make([]*encoder, 0, len(b.encodings))
+	for _, name := range b.encodings {
+		enc, ok := newEncoder(name)
+		if !ok {
+			panic("compress: 不支持的编码 " + name)
+		}
+		encoders = append(encoders, enc)
+	}
+
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			// 替换掉 Resp，这样才知道业务有没有绕开 RespData 直接写响应
+			resp := ctx.Resp
+			tw := &trackingWriter{ResponseWriter: resp}
+			ctx.Resp = tw
+			next(ctx)
+			ctx.Resp = resp
+
+			if tw.written || !b.compressible(ctx) {
+				return
+			}
+			header := resp.Header()
+			header.Add("Vary", "Accept-Encoding")
+			if len(ctx.RespData) < b.minLength {
+				return
+			}
+
+			enc := negotiate(ctx.Req.Header.Get("Accept-Encoding"), encoders)
+			if enc == nil {
+				return
+			}
+			data, err := enc.compress(ctx.RespData)
+			if err != nil {
+				// 压缩失败就原样返回
+				return
+			}
+			header.Set("Content-Encoding", enc.name)
+			if header.Get("Content-Length") != "" {
+				header.Set("Content-Length", strconv.Itoa(len(data)))
+			}
+			// 原本的强 ETag 对应的是未压缩的数据
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+			ctx.RespData = data
+		}
+	}
+}
+
+// compressible 判断响应本身是否适合压缩，不考虑长度
+func (b MiddlewareBuilder) compressible(ctx *web.Context) bool {
+	if ctx.Req.Method == http.MethodHead {
+		return false
+	}
+	switch ctx.RespStatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+
+	header := ctx.Resp.Header()
+	// 已经压缩过了，例如预压缩的静态文件
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		if len(ctx.RespData) == 0 {
+			return false
+		}
+		// 压缩之后 http 包就没有办法探测出正确的类型了，所以要提前探测
+		contentType = http.DetectContentType(ctx.RespData)
+		header.Set("Content-Type", contentType)
+	}
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	for _, prefix := range b.contentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate 根据 Accept-Encoding 选出编码器，选不出来返回 nil
+func negotiate(acceptEncoding string, encoders []*encoder) *encoder {
+	if acceptEncoding == "" {
+		return nil
+	}
+	qs := make(map[string]float64, 4)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if val, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				continue
+			}
+			q = v
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var res *encoder
+	bestQ := 0.0
+	for _, enc := range encoders {
+		q, ok := qs[enc.name]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			res, bestQ = enc, q
+		}
+	}
+	return res
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// encoder 复用压缩器和缓冲区，创建压缩器的开销比压缩本身还要大
+type encoder struct {
+	name    string
+	writers sync.Pool
+	buffers sync.Pool
+}
+
+func newEncoder(name string) (*encoder, bool) {
+	var newWriter func() any
+	switch name {
+	case "gzip":
+		newWriter = func() any {
+			return gzip.NewWriter(nil)
+		}
+	case "br":
+		newWriter = func() any {
+			return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+		}
+	case "zstd":
+		newWriter = func() any {
+			// 默认的并发度会为每个压缩器启动多个 goroutine，放进池子里面不划算
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return w
+		}
+	default:
+		return nil, false
+	}
+	return &encoder{
+		name:    name,
+		writers: sync.Pool{New: newWriter},
+		buffers: sync.Pool{New: func() any {
+			return &bytes.Buffer{}
+		}},
+	}, true
+}
+
+func (e *encoder) compress(data []byte) ([]byte, error) {
+	buf := e.buffers.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		e.buffers.Put(buf)
+	}()
+
+	w := e.writers.Get().(resetWriter)
+	// 出错的 writer 下次用之前也会 Reset，所以总是可以放回池子里面
+	defer e.writers.Put(w)
+	w.Reset(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	// buf 还要放回池子里面，所以要复制一份出来
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// trackingWriter 记录业务代码有没有直接写过响应
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(statusCode int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *trackingWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *trackingWriter) Flush() {
+	w.written = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 让 http.ResponseController 能够拿到原本的 ResponseWriter
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
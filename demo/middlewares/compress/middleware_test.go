@@ -0,0 +1,163 @@
+package compress
+
+import (
+	"bookstore/demo/web"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	page := strings.Repeat("<p>hello, world</p>", 100)
+
+	s := web.NewHTTPServer(web.ServerWithMiddleware(NewMiddlewareBuilder().Build()))
+	s.Get("/page", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(page)
+	})
+	s.Get("/small", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello")
+	})
+	s.Get("/png", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "image/png")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(page)
+	})
+	s.Get("/encoded", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "text/html")
+		ctx.Resp.Header().Set("Content-Encoding", "gzip")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(page)
+	})
+	s.Get("/stream", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "text/html")
+		_, _ = ctx.Resp.Write([]byte(page))
+	})
+
+	testCases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+
+		wantEncoding string
+		wantVary     bool
+	}{
+		{
+			name:           "br preferred",
+			path:           "/page",
+			acceptEncoding: "gzip, deflate, br, zstd",
+			wantEncoding:   "br",
+			wantVary:       true,
+		},
+		{
+			name:           "client q value",
+			path:           "/page",
+			acceptEncoding: "br;q=0.5, gzip",
+			wantEncoding:   "gzip",
+			wantVary:       true,
+		},
+		{
+			name:           "zstd",
+			path:           "/page",
+			acceptEncoding: "zstd",
+			wantEncoding:   "zstd",
+			wantVary:       true,
+		},
+		{
+			name:           "rejected",
+			path:           "/page",
+			acceptEncoding: "*;q=0",
+			wantVary:       true,
+		},
+		{
+			name:     "no accept encoding",
+			path:     "/page",
+			wantVary: true,
+		},
+		{
+			name:           "too small",
+			path:           "/small",
+			acceptEncoding: "gzip",
+			wantVary:       true,
+		},
+		{
+			name:           "not compressible",
+			path:           "/png",
+			acceptEncoding: "gzip",
+		},
+		{
+			name:           "already encoded",
+			path:           "/encoded",
+			acceptEncoding: "br",
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "stream",
+			path:           "/stream",
+			acceptEncoding: "gzip",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantEncoding, recorder.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.wantVary, recorder.Header().Get("Vary") == "Accept-Encoding")
+			if tc.path != "/page" {
+				return
+			}
+			assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, page, decode(t, tc.wantEncoding, recorder.Body.Bytes()))
+		})
+	}
+}
+
+func TestEncoder_Reuse(t *testing.T) {
+	for _, name := range []string{"gzip", "br", "zstd"} {
+		enc, ok := newEncoder(name)
+		require.True(t, ok)
+		// 复用之后数据不能串
+		for _, data := range []string{"first payload", "second"} {
+			res, err := enc.compress([]byte(data))
+			require.NoError(t, err)
+			assert.Equal(t, data, decode(t, name, res))
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, data []byte) string {
+	var r io.Reader = bytes.NewReader(data)
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = gr
+	case "br":
+		r = brotli.NewReader(r)
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	}
+	res, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(res)
+}
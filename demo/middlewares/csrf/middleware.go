@@ -0,0 +1,195 @@
+package csrf
+
+import (
+	"bookstore/demo/web"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// MiddlewareBuilder CSRF 防护
+// 默认使用 double-submit cookie：token 放在 cookie 里面，
+// 不安全的请求需要在请求头或者表单里面再带一次，两者一致才放行
+// 如果设置了 Store，token 就和会话绑定在一起
+type MiddlewareBuilder struct {
+	store      Store
+	headerName string
+	fieldName  string
+	// exemptPaths 不需要校验的路径，以 * 结尾的是前缀匹配
+	exemptPaths []string
+	exemptFuncs []func(ctx *web.Context) bool
+}
+
+// Store 保存 token 的地方
+type Store interface {
+	// Get 读取当前请求对应的 token，没有的话返回空字符串
+	Get(ctx *web.Context) (string, error)
+	// Save 保存新生成的 token
+	Save(ctx *web.Context, token string) error
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		store:      NewCookieStore("csrf_token"),
+		headerName: "X-CSRF-Token",
+		fieldName:  web.CSRFFieldName,
+	}
+}
+
+// Store 设置保存 token 的地方，例如和 session 绑定
+func (b *MiddlewareBuilder) Store(store Store) *MiddlewareBuilder {
+	b.store = store
+	return b
+}
+
+// HeaderName 设置携带 token 的请求头，默认是 X-CSRF-Token
+func (b *MiddlewareBuilder) HeaderName(name string) *MiddlewareBuilder {
+	b.headerName = name
+	return b
+}
+
+// FieldName 设置携带 token 的表单字段，默认是 web.CSRFFieldName
+func (b *MiddlewareBuilder) FieldName(name string) *MiddlewareBuilder {
+	b.fieldName = name
+	return b
+}
+
+// ExemptPaths 这些路径不校验 token，例如 /api/*
+func (b *MiddlewareBuilder) ExemptPaths(paths ...string) *MiddlewareBuilder {
+	b.exemptPaths = append(b.exemptPaths, paths...)
+	return b
+}
+
+// ExemptFunc fn 返回 true 的请求不校验 token
+func (b *MiddlewareBuilder) ExemptFunc(fn func(ctx *web.Context) bool) *MiddlewareBuilder {
+	b.exemptFuncs = append(b.exemptFuncs, fn)
+	return b
+}
+
+// ExemptBearer 使用 Authorization: Bearer 认证的请求不校验 token
+// 浏览器不会自动带上 Authorization 头，所以这类 JSON API 不会受到 CSRF 攻击
+func (b *MiddlewareBuilder) ExemptBearer() *MiddlewareBuilder {
+	return b.ExemptFunc(func(ctx *web.Context) bool {
+		return strings.HasPrefix(ctx.Req.Header.Get("Authorization"), "Bearer ")
+	})
+}
+
+func (b MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if b.exempt(ctx) {
+				next(ctx)
+				return
+			}
+
+			token, err := b.store.Get(ctx)
+			if err != nil {
+				ctx.RespStatusCode = http.StatusInternalServerError
+				ctx.RespData = []byte("服务器错误")
+				return
+			}
+			if !isSafeMethod(ctx.Req.Method) && !b.valid(ctx, token) {
+				ctx.RespStatusCode = http.StatusForbidden
+				ctx.RespData = []byte("CSRF token 校验失败")
+				return
+			}
+
+			if token == "" {
+				token, err = newToken()
+				if err == nil {
+					err = b.store.Save(ctx, token)
+				}
+				if err != nil {
+					ctx.RespStatusCode = http.StatusInternalServerError
+					ctx.RespData = []byte("服务器错误")
+					return
+				}
+			}
+			// 放进 context，模板和 ctx.CSRFToken() 都从这里读取
+			ctx.Req = ctx.Req.WithContext(web.ContextWithCSRFToken(ctx.Req.Context(), token))
+			next(ctx)
+		}
+	}
+}
+
+func (b MiddlewareBuilder) exempt(ctx *web.Context) bool {
+	path := ctx.Req.URL.Path
+	for _, p := range b.exemptPaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	for _, fn := range b.exemptFuncs {
+		if fn(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// valid 先从请求头里面读，读不到再从表单里面读
+func (b MiddlewareBuilder) valid(ctx *web.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	submitted := ctx.Req.Header.Get(b.headerName)
+	if submitted == "" {
+		submitted = ctx.Req.PostFormValue(b.fieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func newToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// CookieStore double-submit cookie 模式下，token 保存在 cookie 里面
+type CookieStore struct {
+	name string
+}
+
+func NewCookieStore(name string) *CookieStore {
+	return &CookieStore{name: name}
+}
+
+func (c *CookieStore) Get(ctx *web.Context) (string, error) {
+	ck, err := ctx.Req.Cookie(c.name)
+	if err == http.ErrNoCookie {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ck.Value, nil
+}
+
+func (c *CookieStore) Save(ctx *web.Context, token string) error {
+	// 前端脚本不需要读 cookie，从页面里面拿 token 就可以
+	ctx.SetCookie(&http.Cookie{
+		Name:     c.name,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   ctx.Req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
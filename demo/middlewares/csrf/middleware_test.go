@@ -0,0 +1,166 @@
+package csrf
+
+import (
+	"bookstore/demo/web"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	engine := web.NewGoTemplateEngine(
+		web.TemplateWithFS(fstest.MapFS{"form.gohtml": {Data: []byte(`<form>{{csrfField}}</form>`)}}),
+		web.TemplateWithRequestFuncs(web.CSRFFuncs))
+	require.NoError(t, engine.LoadPages("*.gohtml"))
+
+	builder := NewMiddlewareBuilder().ExemptPaths("/api/*", "/webhook").ExemptBearer()
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()),
+		web.ServerWithTemplateEngine(engine))
+	s.Get("/form", func(ctx *web.Context) {
+		_ = ctx.Render("form.gohtml", nil)
+	})
+	okHandler := func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("ok")
+	}
+	s.Post("/form", okHandler)
+	s.Post("/api/user", okHandler)
+	s.Post("/webhook", okHandler)
+
+	// 先拿到页面和 cookie
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/form", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	token := cookies[0].Value
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, `<form><input type="hidden" name="csrf_token" value="`+token+`"></form>`,
+		recorder.Body.String())
+
+	testCases := []struct {
+		name    string
+		path    string
+		cookie  string
+		header  map[string]string
+		form    url.Values
+		wantErr bool
+	}{
+		{
+			name:    "no token",
+			path:    "/form",
+			cookie:  token,
+			wantErr: true,
+		},
+		{
+			name:    "no cookie",
+			path:    "/form",
+			header:  map[string]string{"X-CSRF-Token": token},
+			wantErr: true,
+		},
+		{
+			name:   "header",
+			path:   "/form",
+			cookie: token,
+			header: map[string]string{"X-CSRF-Token": token},
+		},
+		{
+			name:   "form field",
+			path:   "/form",
+			cookie: token,
+			form:   url.Values{"csrf_token": {token}},
+		},
+		{
+			name:    "mismatch",
+			path:    "/form",
+			cookie:  token,
+			form:    url.Values{"csrf_token": {"abc"}},
+			wantErr: true,
+		},
+		{
+			name: "exempt prefix",
+			path: "/api/user",
+		},
+		{
+			name: "exempt path",
+			path: "/webhook",
+		},
+		{
+			name:   "bearer",
+			path:   "/form",
+			header: map[string]string{"Authorization": "Bearer xxx.yyy"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tc.cookie})
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			if tc.wantErr {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+				return
+			}
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "ok", recorder.Body.String())
+		})
+	}
+}
+
+// sessionStore 模拟和会话绑定的 token
+type sessionStore map[string]string
+
+func (s sessionStore) Get(ctx *web.Context) (string, error) {
+	return s[ctx.Req.Header.Get("X-Session")], nil
+}
+
+func (s sessionStore) Save(ctx *web.Context, token string) error {
+	s[ctx.Req.Header.Get("X-Session")] = token
+	return nil
+}
+
+func TestMiddlewareBuilder_Store(t *testing.T) {
+	store := sessionStore{}
+	s := web.NewHTTPServer(web.ServerWithMiddleware(NewMiddlewareBuilder().Store(store).Build()))
+	s.Get("/token", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(ctx.CSRFToken())
+	})
+	s.Post("/form", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	req.Header.Set("X-Session", "session-1")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	token := recorder.Body.String()
+	assert.NotEmpty(t, token)
+	assert.Equal(t, token, store["session-1"])
+	assert.Empty(t, recorder.Result().Cookies())
+
+	for session, wantCode := range map[string]int{
+		"session-1": http.StatusOK,
+		"session-2": http.StatusForbidden,
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/form", nil)
+		req.Header.Set("X-Session", session)
+		req.Header.Set("X-CSRF-Token", token)
+		recorder = httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		assert.Equal(t, wantCode, recorder.Code, session)
+	}
+}
@@ -42,7 +42,12 @@ func (b MiddlewareBuilder) Build() web.Middleware {
 			span.SetAttributes(attribute.String("span.kind", "server"))
 			span.SetAttributes(attribute.String("component", "web"))
 			span.SetAttributes(attribute.String("peer.address", ctx.Req.RemoteAddr))
+			span.SetAttributes(attribute.String("http.client_ip", ctx.ClientIP()))
 			span.SetAttributes(attribute.String("http.proto", ctx.Req.Proto))
+			// requestid 中间件在前面的话，这里就已经有请求 ID 了
+			if id := ctx.RequestID(); id != "" {
+				span.SetAttributes(attribute.String("http.request_id", id))
+			}
 
 			ctx.Req = ctx.Req.WithContext(reqCtx)
 			// 直接调用下一步
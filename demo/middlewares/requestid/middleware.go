@@ -0,0 +1,85 @@
+package requestid
+
+import (
+	"bookstore/demo/web"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderName 默认的请求 ID 头部
+const HeaderName = "X-Request-ID"
+
+// maxLength 客户端传过来的 ID 太长的话就不要了，避免撑爆日志
+const maxLength = 128
+
+// MiddlewareBuilder 为每个请求分配一个 ID
+// 客户端（或者上游网关）已经带了合法的 ID 就沿用，否则生成一个新的。
+// ID 会回写到响应头，放进 ctx.Req 的 context，并且加到当前的 span 上
+type MiddlewareBuilder struct {
+	headerName    string
+	generator     func() string
+	trustIncoming bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		headerName:    HeaderName,
+		generator:     uuid.NewString,
+		trustIncoming: true,
+	}
+}
+
+func (b *MiddlewareBuilder) HeaderName(name string) *MiddlewareBuilder {
+	b.headerName = name
+	return b
+}
+
+// Generator 替换 ID 的生成方式，例如使用 snowflake
+func (b *MiddlewareBuilder) Generator(fn func() string) *MiddlewareBuilder {
+	b.generator = fn
+	return b
+}
+
+// TrustIncoming 是否沿用请求里面带过来的 ID，默认沿用
+// 直接暴露在公网的服务可以关掉，避免 ID 被客户端伪造
+func (b *MiddlewareBuilder) TrustIncoming(trust bool) *MiddlewareBuilder {
+	b.trustIncoming = trust
+	return b
+}
+
+func (b MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			id := ""
+			if b.trustIncoming {
+				id = ctx.Req.Header.Get(b.headerName)
+			}
+			if !Valid(id) {
+				id = b.generator()
+			}
+
+			ctx.Resp.Header().Set(b.headerName, id)
+			reqCtx := web.ContextWithRequestID(ctx.Req.Context(), id)
+			// 如果前面已经有 opentelemetry 中间件，就把 ID 记到它的 span 上
+			trace.SpanFromContext(reqCtx).SetAttributes(attribute.String("http.request_id", id))
+			ctx.Req = ctx.Req.WithContext(reqCtx)
+			next(ctx)
+		}
+	}
+}
+
+// Valid 判断一个外部传进来的 ID 能不能用
+// 只接受可见的 ASCII 字符，防止日志注入
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
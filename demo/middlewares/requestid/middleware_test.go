@@ -0,0 +1,103 @@
+package requestid
+
+import (
+	"bookstore/demo/middlewares/opentelemetry"
+	"bookstore/demo/web"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *MiddlewareBuilder
+		reqID   string
+
+		wantID string
+	}{
+		{
+			name:    "generate",
+			builder: NewMiddlewareBuilder(),
+		},
+		{
+			name:    "accept",
+			builder: NewMiddlewareBuilder(),
+			reqID:   "abc-123",
+			wantID:  "abc-123",
+		},
+		{
+			name:    "invalid",
+			builder: NewMiddlewareBuilder(),
+			reqID:   "abc\n123",
+		},
+		{
+			name:    "too long",
+			builder: NewMiddlewareBuilder(),
+			reqID:   strings.Repeat("a", 129),
+		},
+		{
+			name:    "not trust",
+			builder: NewMiddlewareBuilder().TrustIncoming(false),
+			reqID:   "abc-123",
+		},
+		{
+			name: "generator",
+			builder: NewMiddlewareBuilder().Generator(func() string {
+				return "12345"
+			}),
+			wantID: "12345",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := web.NewHTTPServer(web.ServerWithMiddleware(tc.builder.Build()))
+			var ctxID string
+			s.Get("/user", func(ctx *web.Context) {
+				ctxID = ctx.RequestID()
+			})
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if tc.reqID != "" {
+				req.Header.Set(HeaderName, tc.reqID)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+
+			respID := recorder.Header().Get(HeaderName)
+			assert.Equal(t, ctxID, respID)
+			if tc.wantID != "" {
+				assert.Equal(t, tc.wantID, respID)
+				return
+			}
+			_, err := uuid.Parse(respID)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Span(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	otelMdl := opentelemetry.MiddlewareBuilder{Tracer: tp.Tracer("test")}.Build()
+	s := web.NewHTTPServer(web.ServerWithMiddleware(otelMdl, NewMiddlewareBuilder().Build()))
+	s.Get("/user", func(ctx *web.Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set(HeaderName, "abc-123")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.request_id", "abc-123"))
+}
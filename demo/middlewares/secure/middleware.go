@@ -0,0 +1,129 @@
+package secure
+
+import (
+	"bookstore/demo/web"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NoncePlaceholder CSP 里面的占位符，每个请求都会被替换成新的 nonce
+// 例如 script-src 'self' 'nonce-{nonce}'
+const NoncePlaceholder = "{nonce}"
+
+// MiddlewareBuilder 设置安全相关的响应头
+// 在 next 之前设置，所以业务代码依旧可以覆盖
+type MiddlewareBuilder struct {
+	hsts              string
+	csp               string
+	frameOptions      string
+	referrerPolicy    string
+	permissionsPolicy string
+	noSniff           bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		frameOptions:   "DENY",
+		referrerPolicy: "strict-origin-when-cross-origin",
+		noSniff:        true,
+	}
+}
+
+// HSTS 设置 Strict-Transport-Security，maxAge 为 0 就是不设置
+// 浏览器会忽略通过 HTTP 收到的 HSTS，所以不需要区分请求是不是 HTTPS
+func (b *MiddlewareBuilder) HSTS(maxAge time.Duration, includeSubDomains bool, preload bool) *MiddlewareBuilder {
+	if maxAge <= 0 {
+		b.hsts = ""
+		return b
+	}
+	val := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if includeSubDomains {
+		val += "; includeSubDomains"
+	}
+	if preload {
+		val += "; preload"
+	}
+	b.hsts = val
+	return b
+}
+
+// CSP 设置 Content-Security-Policy
+// 如果 policy 里面有 NoncePlaceholder，每个请求都会生成新的 nonce，
+// 模板里面通过 web.CSPFuncs 提供的 cspNonce 拿到
+func (b *MiddlewareBuilder) CSP(policy string) *MiddlewareBuilder {
+	b.csp = policy
+	return b
+}
+
+// FrameOptions 设置 X-Frame-Options，默认是 DENY，空字符串就是不设置
+func (b *MiddlewareBuilder) FrameOptions(val string) *MiddlewareBuilder {
+	b.frameOptions = val
+	return b
+}
+
+// ReferrerPolicy 设置 Referrer-Policy，默认是 strict-origin-when-cross-origin
+func (b *MiddlewareBuilder) ReferrerPolicy(val string) *MiddlewareBuilder {
+	b.referrerPolicy = val
+	return b
+}
+
+// PermissionsPolicy 设置 Permissions-Policy，例如 camera=(), geolocation=(self)
+func (b *MiddlewareBuilder) PermissionsPolicy(val string) *MiddlewareBuilder {
+	b.permissionsPolicy = val
+	return b
+}
+
+// NoSniff 是否设置 X-Content-Type-Options: nosniff，默认设置
+func (b *MiddlewareBuilder) NoSniff(enabled bool) *MiddlewareBuilder {
+	b.noSniff = enabled
+	return b
+}
+
+func (b MiddlewareBuilder) Build() web.Middleware {
+	useNonce := strings.Contains(b.csp, NoncePlaceholder)
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			header := ctx.Resp.Header()
+			setIfNotEmpty(header, "Strict-Transport-Security", b.hsts)
+			setIfNotEmpty(header, "X-Frame-Options", b.frameOptions)
+			setIfNotEmpty(header, "Referrer-Policy", b.referrerPolicy)
+			setIfNotEmpty(header, "Permissions-Policy", b.permissionsPolicy)
+			if b.noSniff {
+				header.Set("X-Content-Type-Options", "nosniff")
+			}
+
+			csp := b.csp
+			if useNonce {
+				nonce, err := newNonce()
+				if err != nil {
+					ctx.RespStatusCode = http.StatusInternalServerError
+					ctx.RespData = []byte("服务器错误")
+					return
+				}
+				csp = strings.ReplaceAll(csp, NoncePlaceholder, nonce)
+				ctx.Req = ctx.Req.WithContext(web.ContextWithCSPNonce(ctx.Req.Context(), nonce))
+			}
+			setIfNotEmpty(header, "Content-Security-Policy", csp)
+			next(ctx)
+		}
+	}
+}
+
+func setIfNotEmpty(header http.Header, key string, val string) {
+	if val != "" {
+		header.Set(key, val)
+	}
+}
+
+func newNonce() (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	// URL 安全的字母表，避免模板把 + 转义成 &#43;
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
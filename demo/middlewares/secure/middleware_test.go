@@ -0,0 +1,58 @@
+package secure
+
+import (
+	"bookstore/demo/web"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	engine := web.NewGoTemplateEngine(
+		web.TemplateWithFS(fstest.MapFS{"page.gohtml": {Data: []byte(`<script nonce="{{cspNonce}}"></script>`)}}),
+		web.TemplateWithRequestFuncs(web.CSPFuncs))
+	require.NoError(t, engine.LoadPages("*.gohtml"))
+
+	builder := NewMiddlewareBuilder().
+		HSTS(365*24*time.Hour, true, false).
+		CSP("default-src 'self'; script-src 'self' 'nonce-{nonce}'").
+		PermissionsPolicy("camera=()")
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()),
+		web.ServerWithTemplateEngine(engine))
+	s.Get("/page", func(ctx *web.Context) {
+		_ = ctx.Render("page.gohtml", nil)
+	})
+	s.Get("/embed", func(ctx *web.Context) {
+		// 业务可以覆盖
+		ctx.Resp.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	})
+
+	nonces := make(map[string]struct{}, 2)
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/page", nil))
+		header := recorder.Header()
+		assert.Equal(t, "max-age=31536000; includeSubDomains", header.Get("Strict-Transport-Security"))
+		assert.Equal(t, "DENY", header.Get("X-Frame-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", header.Get("Referrer-Policy"))
+		assert.Equal(t, "camera=()", header.Get("Permissions-Policy"))
+		assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+
+		csp := header.Get("Content-Security-Policy")
+		nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "default-src 'self'; script-src 'self' 'nonce-"), "'")
+		require.NotEqual(t, csp, nonce)
+		assert.Equal(t, `<script nonce="`+nonce+`"></script>`, recorder.Body.String())
+		nonces[nonce] = struct{}{}
+	}
+	assert.Len(t, nonces, 2)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/embed", nil))
+	assert.Equal(t, "SAMEORIGIN", recorder.Header().Get("X-Frame-Options"))
+}
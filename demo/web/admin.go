@@ -0,0 +1,252 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc 就绪检查，例如 db.PingContext，
+// 或者 func(ctx context.Context) error { return rdb.Ping(ctx).Err() }
+type CheckFunc func(ctx context.Context) error
+
+type AdminOption func(admin *adminServer)
+
+// adminServer 管理端口，和业务端口分开，避免把 pprof 之类的东西暴露到公网
+// - /healthz 存活检查，进程还活着就返回 200
+// - /readyz 就绪检查，HTTPServer 开始监听之后，所有的 CheckFunc 都通过才返回 200，
+// 关闭的时候会第一时间变成 503，让负载均衡摘掉流量
+// - /buildinfo 构建信息
+// - /debug/stats 运行时的统计数据
+// - /debug/pprof/ pprof
+type adminServer struct {
+	addr       string
+	checks     []namedCheck
+	timeout    time.Duration
+	drainDelay time.Duration
+	version    string
+	pprof      bool
+
+	ready     atomic.Bool
+	startTime time.Time
+
+	handler *HTTPServer
+
+	// start 和 shutdown 在不同的 goroutine 上调用
+	mutex  sync.Mutex
+	server *http.Server
+	// closed shutdown 之后不允许再 start
+	closed bool
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+// ServerWithAdmin 在 addr 上开一个管理端口，跟随 HTTPServer 的 Start 和 Shutdown 启动和关闭
+func ServerWithAdmin(addr string, opts ...AdminOption) HTTPServerOption {
+	return func(server *HTTPServer) {
+		admin := &adminServer{
+			addr:    addr,
+			timeout: time.Second,
+			pprof:   true,
+		}
+		for _, opt := range opts {
+			opt(admin)
+		}
+		admin.handler = admin.newHandler()
+		server.admin = admin
+	}
+}
+
+// AdminWithReadinessCheck 增加一个就绪检查，按照注册的顺序执行
+func AdminWithReadinessCheck(name string, check CheckFunc) AdminOption {
+	return func(admin *adminServer) {
+		admin.checks = append(admin.checks, namedCheck{name: name, check: check})
+	}
+}
+
+// AdminWithCheckTimeout 单个就绪检查的超时时间，默认一秒
+func AdminWithCheckTimeout(timeout time.Duration) AdminOption {
+	return func(admin *adminServer) {
+		admin.timeout = timeout
+	}
+}
+
+// AdminWithDrainDelay 关闭的时候，/readyz 变成 503 之后等多久才开始关闭业务端口
+// 给负载均衡留出摘流量的时间，默认不等待
+func AdminWithDrainDelay(delay time.Duration) AdminOption {
+	return func(admin *adminServer) {
+		admin.drainDelay = delay
+	}
+}
+
+// AdminWithVersion 在 /buildinfo 里面输出的版本号，一般在编译的时候通过 -ldflags 注入
+func AdminWithVersion(version string) AdminOption {
+	return func(admin *adminServer) {
+		admin.version = version
+	}
+}
+
+// AdminWithPprof 是否暴露 /debug/pprof/，默认暴露
+func AdminWithPprof(enabled bool) AdminOption {
+	return func(admin *adminServer) {
+		admin.pprof = enabled
+	}
+}
+
+func (a *adminServer) newHandler() *HTTPServer {
+	s := NewHTTPServer()
+	s.Get("/healthz", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("ok")
+	})
+	s.Get("/readyz", a.readyz)
+	s.Get("/buildinfo", a.buildInfo)
+	s.Get("/debug/stats", a.stats)
+	if a.pprof {
+		s.Get("/debug/pprof", pprofHandler)
+		s.Get("/debug/pprof/*", pprofHandler)
+		s.Post("/debug/pprof/*", pprofHandler)
+	}
+	return s
+}
+
+type readyResult struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (a *adminServer) readyz(ctx *Context) {
+	if !a.ready.Load() {
+		_ = ctx.RespJSON(http.StatusServiceUnavailable, readyResult{Status: "not ready"})
+		return
+	}
+
+	res := readyResult{Status: "ok", Checks: make(map[string]string, len(a.checks))}
+	code := http.StatusOK
+	for _, c := range a.checks {
+		checkCtx, cancel := context.WithTimeout(ctx.Req.Context(), a.timeout)
+		err := c.check(checkCtx)
+		cancel()
+		if err != nil {
+			res.Status = "not ready"
+			res.Checks[c.name] = err.Error()
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[c.name] = "ok"
+	}
+	_ = ctx.RespJSON(code, res)
+}
+
+type buildInfo struct {
+	Version   string            `json:"version,omitempty"`
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path,omitempty"`
+	Main      string            `json:"main,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+func (a *adminServer) buildInfo(ctx *Context) {
+	res := buildInfo{Version: a.version, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		res.Path = info.Path
+		res.Main = info.Main.Version
+		res.Settings = make(map[string]string, len(info.Settings))
+		for _, setting := range info.Settings {
+			res.Settings[setting.Key] = setting.Value
+		}
+	}
+	_ = ctx.RespJSONOK(res)
+}
+
+type runtimeStats struct {
+	Uptime       string `json:"uptime"`
+	Goroutines   int    `json:"goroutines"`
+	NumCPU       int    `json:"num_cpu"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+}
+
+func (a *adminServer) stats(ctx *Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	res := runtimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapObjects:  mem.HeapObjects,
+		TotalAlloc:   mem.TotalAlloc,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	}
+	if !a.startTime.IsZero() {
+		res.Uptime = time.Since(a.startTime).Round(time.Second).String()
+	}
+	_ = ctx.RespJSONOK(res)
+}
+
+// pprofHandler pprof 的 handler 依赖 /debug/pprof/ 这个前缀，所以不能用 Mount
+func pprofHandler(ctx *Context) {
+	w := &statusWriter{ResponseWriter: ctx.Resp}
+	switch strings.TrimPrefix(ctx.Req.URL.Path, "/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, ctx.Req)
+	case "profile":
+		pprof.Profile(w, ctx.Req)
+	case "symbol":
+		pprof.Symbol(w, ctx.Req)
+	case "trace":
+		pprof.Trace(w, ctx.Req)
+	default:
+		pprof.Index(w, ctx.Req)
+	}
+	ctx.RespStatusCode = w.statusCode()
+	ctx.respFlushed = true
+}
+
+func (a *adminServer) start() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return http.ErrServerClosed
+	}
+	l, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	a.startTime = time.Now()
+	srv := &http.Server{Handler: a.handler}
+	a.server = srv
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	return nil
+}
+
+func (a *adminServer) shutdown(ctx context.Context) error {
+	a.mutex.Lock()
+	a.closed = true
+	srv := a.server
+	a.mutex.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminServer_Handler(t *testing.T) {
+	redisErr := errors.New("redis: connection refused")
+	var redisDown bool
+	s := NewHTTPServer(ServerWithAdmin(":0",
+		AdminWithVersion("v1.2.3"),
+		AdminWithReadinessCheck("mysql", func(ctx context.Context) error {
+			return nil
+		}),
+		AdminWithReadinessCheck("redis", func(ctx context.Context) error {
+			if redisDown {
+				return redisErr
+			}
+			return nil
+		})))
+	admin := s.admin
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		admin.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := get("/healthz")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "ok", recorder.Body.String())
+
+	// 还没有开始监听
+	recorder = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.JSONEq(t, `{"status":"not ready"}`, recorder.Body.String())
+
+	admin.ready.Store(true)
+	recorder = get("/readyz")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":{"mysql":"ok","redis":"ok"}}`, recorder.Body.String())
+
+	redisDown = true
+	recorder = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.JSONEq(t, `{"status":"not ready","checks":{"mysql":"ok","redis":"redis: connection refused"}}`,
+		recorder.Body.String())
+
+	recorder = get("/buildinfo")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var info buildInfo
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+
+	recorder = get("/debug/stats")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var stats runtimeStats
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	assert.Greater(t, stats.Goroutines, 0)
+
+	recorder = get("/debug/pprof/")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "goroutine")
+	recorder = get("/debug/pprof/goroutine?debug=1")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "goroutine profile")
+
+	// 关掉 pprof
+	s = NewHTTPServer(ServerWithAdmin(":0", AdminWithPprof(false)))
+	recorder = httptest.NewRecorder()
+	s.admin.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHTTPServer_ShutdownBeforeStart(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []HTTPServerOption
+	}{
+		{
+			name: "with admin",
+			opts: []HTTPServerOption{ServerWithAdmin(freeAddr(t))},
+		},
+		{
+			name: "without admin",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(tc.opts...)
+			require.NoError(t, s.Shutdown(context.Background()))
+
+			// 已经关闭了，不能再启动
+			assert.ErrorIs(t, s.Start(freeAddr(t)), http.ErrServerClosed)
+		})
+	}
+}
+
+func TestHTTPServer_Shutdown(t *testing.T) {
+	addr, adminAddr := freeAddr(t), freeAddr(t)
+	s := NewHTTPServer(ServerWithAdmin(adminAddr, AdminWithDrainDelay(200*time.Millisecond)))
+	started := make(chan struct{})
+	s.Get("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("done")
+	})
+
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- s.Start(addr)
+	}()
+	require.Eventually(t, func() bool {
+		return statusOf("http://"+adminAddr+"/readyz") == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	slowResp := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slowResp <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slowResp <- string(body)
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+	// 先摘流量，业务端口还在
+	require.Eventually(t, func() bool {
+		return statusOf("http://"+adminAddr+"/readyz") == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	// 正在处理的请求会被处理完
+	assert.Equal(t, "done", <-slowResp)
+	require.NoError(t, <-shutdownErr)
+	require.NoError(t, <-startErr)
+	assert.Equal(t, 0, statusOf("http://"+adminAddr+"/healthz"))
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// statusOf 连不上就返回 0
+func statusOf(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
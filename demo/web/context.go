@@ -1,11 +1,20 @@
 package web
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 /*
@@ -27,15 +36,36 @@ type Context struct {
 	RespStatusCode int
 
 	PathParams map[string]string
+	// HostParams 域名参数，例如 :tenant.example.com 里面的 tenant
+	HostParams map[string]string
 
 	//Ctx context.Context
 
 	// 缓存的数据
 	cacheQueryValues url.Values
+	cacheFormValues  url.Values
+	formParsed       bool
+	formErr          error
 	// 命中的路由
 	MatchedRoute string
 
 	tplEngine TemplateEngine
+
+	trustedProxies []*net.IPNet
+
+	// rawBody 原始的请求体，SetMaxBodySize 在它的基础上限制长度
+	rawBody     io.ReadCloser
+	maxBodySize int64
+	// 缓存的请求体，调用了 Body 之后才有
+	body       []byte
+	bodyCached bool
+
+	jsonUseNumber             bool
+	jsonDisallowUnknownFields bool
+
+	// respFlushed 响应已经直接写到了 Resp 里面，例如 RenderStream
+	// 这时候 HTTPServer 不会再回写 RespData
+	respFlushed bool
 }
 
 // 没特别大必要，因为一般都是使用 200 作为状态码
@@ -63,6 +93,189 @@ func (c *Context) Render(tplName string, data any) error {
 	return nil
 }
 
+// RenderStream 渲染页面，适合大页面
+// 如果模板引擎实现了 StreamingTemplateEngine，渲染结果会直接写入 Resp，
+// 不再经过 RespData，其它中间件将无法修改响应；否则和 Render 一样
+// 状态码默认是 200，也可以在调用之前设置 RespStatusCode，比如 404 页面
+// 如果渲染出错的时候已经写出了部分数据，那么状态码已经写出去了，无法再修改
+func (c *Context) RenderStream(tplName string, data any) error {
+	engine, ok := c.tplEngine.(StreamingTemplateEngine)
+	if !ok {
+		return c.Render(tplName, data)
+	}
+
+	header := c.Resp.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	status := c.RespStatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	// 第一次写数据之前才写状态码，这样什么都还没写的时候还能改成 500
+	w := &countingWriter{w: c.Resp, writeHeader: func() {
+		c.Resp.WriteHeader(status)
+	}}
+	err := engine.RenderTo(c.Req.Context(), tplName, data, w)
+	if err != nil && w.n == 0 {
+		// 什么都还没写，还来得及返回 500
+		c.RespStatusCode = http.StatusInternalServerError
+		return err
+	}
+	w.flushHeader()
+	c.respFlushed = true
+	c.RespStatusCode = status
+	return err
+}
+
+// CSRFToken 当前请求的 CSRF token，需要配合 csrf 中间件使用
+// 模板里面可以通过 CSRFFuncs 提供的 csrfToken 和 csrfField 拿到
+func (c *Context) CSRFToken() string {
+	return CSRFTokenFromContext(c.Req.Context())
+}
+
+// CSPNonce 当前请求的 CSP nonce，需要配合 secure 中间件使用
+func (c *Context) CSPNonce() string {
+	return CSPNonceFromContext(c.Req.Context())
+}
+
+// RequestID 当前请求的 ID，需要配合 requestid 中间件使用
+func (c *Context) RequestID() string {
+	return RequestIDFromContext(c.Req.Context())
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 把请求 ID 放进 context，方便往下游传递
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 读取请求 ID，没有就返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ClientIP 客户端的真实 IP
+// 只有直接连过来的是可信代理（见 ServerWithTrustedProxies），才会依次解析
+// Forwarded、X-Forwarded-For 和 X-Real-IP，否则这些头部都可以被客户端伪造
+// 代理链从右往左看，第一个不可信的地址就是客户端
+func (c *Context) ClientIP() string {
+	remote := c.Req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !c.isTrustedProxy(remote) {
+		return remote
+	}
+
+	if chain := parseForwarded(c.Req.Header.Values("Forwarded")); len(chain) > 0 {
+		return c.rightmostUntrusted(chain)
+	}
+	if chain := parseXForwardedFor(c.Req.Header.Values("X-Forwarded-For")); len(chain) > 0 {
+		return c.rightmostUntrusted(chain)
+	}
+	if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remote
+}
+
+func (c *Context) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range c.trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// rightmostUntrusted 全部都是可信代理的话，返回最左边的那个
+func (c *Context) rightmostUntrusted(chain []string) string {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !c.isTrustedProxy(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+// parseXForwardedFor 解析 X-Forwarded-For: client, proxy1, proxy2
+// 出现非法地址就截断，只保留它右边可以校验的部分
+func parseXForwardedFor(values []string) []string {
+	var res []string
+	for _, value := range values {
+		for _, ip := range strings.Split(value, ",") {
+			ip = strings.TrimSpace(ip)
+			if net.ParseIP(ip) == nil {
+				res = res[:0]
+				continue
+			}
+			res = append(res, ip)
+		}
+	}
+	return res
+}
+
+// parseForwarded 解析 RFC 7239 的 Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func parseForwarded(values []string) []string {
+	var res []string
+	for _, value := range values {
+		for _, elem := range strings.Split(value, ",") {
+			ip := ""
+			for _, pair := range strings.Split(elem, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				ip = parseForwardedNode(strings.Trim(val, `"`))
+			}
+			if ip == "" {
+				// unknown 或者混淆过的标识，没有办法继续往左信任了
+				res = res[:0]
+				continue
+			}
+			res = append(res, ip)
+		}
+	}
+	return res
+}
+
+// parseForwardedNode 去掉端口和 IPv6 的方括号
+func parseForwardedNode(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	if net.ParseIP(node) == nil {
+		return ""
+	}
+	return node
+}
+
+// flushTo 把缓存的响应写到 w
+// 已经写过的话什么也不做，写完之后 HTTPServer 也不会再回写
+func (c *Context) flushTo(w http.ResponseWriter) error {
+	if c.respFlushed {
+		return nil
+	}
+	c.respFlushed = true
+	if c.RespStatusCode != 0 {
+		w.WriteHeader(c.RespStatusCode)
+	}
+	// 304 之类的响应是不允许有 body 的
+	if len(c.RespData) == 0 {
+		return nil
+	}
+	_, err := w.Write(c.RespData)
+	return err
+}
+
 func (c *Context) SetCookie(cookie *http.Cookie) {
 	// 不推荐
 	// cookie.SameSite = c.cookieSameSite
@@ -85,50 +298,154 @@ func (c *Context) RespJSONOK(val any) error {
 	return c.RespJSON(http.StatusOK, val)
 }
 
+// ErrBodyTooLarge 请求体超过了 ServerWithMaxBodySize 或者 MaxBodySize 设置的大小
+var ErrBodyTooLarge = errors.New("web: 请求体过大")
+
+// SetMaxBodySize 限制请求体的最大长度，n <= 0 表示不限制
+// 必须在读取请求体之前调用，已经调用过 Body 的话没有效果
+func (c *Context) SetMaxBodySize(n int64) {
+	if c.bodyCached || c.Req.Body == nil {
+		return
+	}
+	if c.rawBody == nil {
+		c.rawBody = c.Req.Body
+	}
+	c.maxBodySize = n
+	if n <= 0 {
+		c.Req.Body = c.rawBody
+		return
+	}
+	c.Req.Body = http.MaxBytesReader(c.Resp, c.rawBody, n)
+}
+
+// Body 读取整个请求体并且缓存起来，多次调用返回同一份数据
+// 读完之后 Req.Body 会被替换成缓存的数据，所以后面的 middleware 和 ParseForm 之类的方法依旧可以读
+// 请求体过大的时候会把响应设置为 413
+func (c *Context) Body() ([]byte, error) {
+	if !c.bodyCached {
+		if c.Req.Body == nil {
+			return nil, errors.New("web: body 为 nil")
+		}
+		data, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			err = bodyErr(err)
+			if errors.Is(err, ErrBodyTooLarge) {
+				c.RespStatusCode = http.StatusRequestEntityTooLarge
+				c.RespData = []byte("请求体过大")
+			}
+			return nil, err
+		}
+		c.body = data
+		c.bodyCached = true
+	}
+	c.Req.Body = io.NopCloser(bytes.NewReader(c.body))
+	return c.body, nil
+}
+
+// BindJSON 使用 ServerWithJSONDecoder 设置的选项解析 JSON
 func (c *Context) BindJSON(val any) error {
-	if c.Req.Body == nil {
+	return c.BindJSONOpt(val, c.jsonUseNumber, c.jsonDisallowUnknownFields)
+}
+
+// BindJSONOpt 解析 JSON 请求体
+// 如果之前调用过 Body，就解析缓存的数据；否则直接从 Req.Body 流式解析，不会把整个请求体读到内存里，
+// 但是之后就没办法再读 body 了，需要重复读的 middleware 应该先调用 Body
+// 出错的时候会设置好响应：请求体过大返回 413，其它的返回 400，用户可以自己覆盖
+func (c *Context) BindJSONOpt(val any, useNumber bool, disableUnknown bool) error {
+	var reader io.Reader
+	if c.bodyCached {
+		reader = bytes.NewReader(c.body)
+	} else if c.Req.Body != nil {
+		reader = c.Req.Body
+	} else {
 		return errors.New("web: body 为 nil")
 	}
 
-	decoder := json.NewDecoder(c.Req.Body)
-	return decoder.Decode(val)
+	decoder := json.NewDecoder(reader)
+	// useNumber => 数字就是用 Number 来表示
+	// 否则默认是 float64
+	if useNumber {
+		decoder.UseNumber()
+	}
+	// 如果要是有一个未知的字段，就会报错
+	// 比如说你 User 只有 Name 和 Email 两个字段
+	// JSON 里面额外多了一个 Age 字段，那么就会报错
+	if disableUnknown {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(val)
+	if err == nil {
+		return nil
+	}
+	err = bodyErr(err)
+	if errors.Is(err, ErrBodyTooLarge) {
+		c.RespStatusCode = http.StatusRequestEntityTooLarge
+		c.RespData = []byte("请求体过大")
+	} else {
+		c.RespStatusCode = http.StatusBadRequest
+		c.RespData = []byte("请求体格式错误")
+	}
+	return err
 }
 
-//func (c *Context) BindJSONOpt(val any, useNumber bool, disableUnknown bool) error {
-//	if c.Req.Body == nil {
-//		return errors.New("web: body 为 nil")
-//	}
-//
-//	decoder := json.NewDecoder(c.Req.Body)
-//	// useNumber => 数字就是用 Number 来表示
-//	// 否则默认是 float64
-//	// decoder.UseNumber()
-//	if useNumber {
-//		decoder.UseNumber()
-//	}
-//	// 如果要是有一个未知的字段，就会报错
-//	// 比如说你 User 只有 Name 和 Email 两个字段
-//	// JSON 里面额外多了一个 Age 字段，那么就会报错
-//	if disableUnknown {
-//		decoder.DisallowUnknownFields()
-//	}
-//	return decoder.Decode(val)
-//}
+// bodyErr 把 http.MaxBytesReader 的错误转成 ErrBodyTooLarge
+func bodyErr(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return fmt.Errorf("%w: 最多 %d 字节", ErrBodyTooLarge, maxErr.Limit)
+	}
+	return err
+}
+
+// ErrKeyNotFound 请求里面没有这个参数
+var ErrKeyNotFound = errors.New("web: 找不到这个 key")
+
+// defaultMaxMemory 和 http.Request.FormValue 保持一致
+const defaultMaxMemory = 32 << 20
+
+// parseForm 表单只解析一次，结果缓存起来
+func (c *Context) parseForm() (url.Values, error) {
+	if !c.formParsed {
+		if strings.HasPrefix(c.Req.Header.Get("Content-Type"), "multipart/form-data") {
+			c.formErr = c.Req.ParseMultipartForm(defaultMaxMemory)
+		} else {
+			c.formErr = c.Req.ParseForm()
+		}
+		c.cacheFormValues = c.Req.Form
+		c.formParsed = true
+	}
+	return c.cacheFormValues, c.formErr
+}
 
 func (c *Context) FormValue(key string) (string, error) {
-	if err := c.Req.ParseForm(); err != nil {
+	form, err := c.parseForm()
+	if err != nil {
 		return "", err
 	}
 
-	return c.Req.FormValue(key), nil
+	return form.Get(key), nil
 }
 
 func (c *Context) FormValueV2(key string) StringValue {
-	if err := c.Req.ParseForm(); err != nil {
-		return StringValue{err: err}
+	form, err := c.parseForm()
+	if err != nil {
+		return StringValue{key: key, err: err}
 	}
 
-	return StringValue{val: c.Req.FormValue(key)}
+	return StringValue{key: key, val: form.Get(key)}
+}
+
+// FormValues 同名参数的所有值，例如多选框
+func (c *Context) FormValues(key string) ([]string, error) {
+	form, err := c.parseForm()
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := form[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return vals, nil
 }
 
 //func (c *Context) FormValueAsInt64(key string) (int64, error) {
@@ -145,13 +462,9 @@ func (c *Context) QueryValue(key string) (string, error) {
 	// 用户区别不出来是真的有值，但是值恰好是空字符串
 	// 还是没有值
 	//return c.Req.URL.Query().Get(key), nil
-	if c.cacheQueryValues == nil {
-		c.cacheQueryValues = c.Req.URL.Query()
-	}
-
-	vals, ok := c.cacheQueryValues[key]
-	if !ok {
-		return "", errors.New("web: 找不到这个 key")
+	vals, err := c.QueryValues(key)
+	if err != nil {
+		return "", err
 	}
 	return vals[0], nil
 }
@@ -160,21 +473,30 @@ func (c *Context) QueryValueV2(key string) StringValue {
 	// 用户区别不出来是真的有值，但是值恰好是空字符串
 	// 还是没有值
 	//return c.Req.URL.Query().Get(key), nil
+	vals, err := c.QueryValues(key)
+	if err != nil {
+		return StringValue{key: key, err: err}
+	}
+	return StringValue{key: key, val: vals[0]}
+}
+
+// QueryValues 同名参数的所有值，例如 ?id=1&id=2
+func (c *Context) QueryValues(key string) ([]string, error) {
 	if c.cacheQueryValues == nil {
 		c.cacheQueryValues = c.Req.URL.Query()
 	}
 
 	vals, ok := c.cacheQueryValues[key]
 	if !ok {
-		return StringValue{err: errors.New("web: 找不到这个 key")}
+		return nil, ErrKeyNotFound
 	}
-	return StringValue{val: vals[0]}
+	return vals, nil
 }
 
 func (c *Context) PathValue(key string) (string, error) {
 	val, ok := c.PathParams[key]
 	if !ok {
-		return "", errors.New("web: 找不到这个 key")
+		return "", ErrKeyNotFound
 	}
 	return val, nil
 }
@@ -182,12 +504,24 @@ func (c *Context) PathValue(key string) (string, error) {
 func (c *Context) PathValueV2(key string) StringValue {
 	val, ok := c.PathParams[key]
 	if !ok {
-		return StringValue{err: errors.New("web: 找不到这个 key")}
+		return StringValue{key: key, err: ErrKeyNotFound}
 	}
-	return StringValue{val: val}
+	return StringValue{key: key, val: val}
 }
 
+// HostValue 域名参数，见 HTTPServer.Host
+func (c *Context) HostValue(key string) StringValue {
+	val, ok := c.HostParams[key]
+	if !ok {
+		return StringValue{key: key, err: ErrKeyNotFound}
+	}
+	return StringValue{key: key, val: val}
+}
+
+// StringValue 参数的原始值，提供各种类型转换
+// 可以链式调用，例如 ctx.QueryValueV2("page").Default("1").ToInt()
 type StringValue struct {
+	key string
 	val string
 	err error
 }
@@ -196,6 +530,23 @@ func (s StringValue) String() (string, error) {
 	return s.val, s.err
 }
 
+// Default 没有这个参数或者值是空字符串的时候，使用默认值
+// 其它错误（例如表单解析失败）依旧会保留
+func (s StringValue) Default(val string) StringValue {
+	if errors.Is(s.err, ErrKeyNotFound) || (s.err == nil && s.val == "") {
+		return StringValue{key: s.key, val: val}
+	}
+	return s
+}
+
+// Required 没有这个参数或者值是空字符串，都返回错误
+func (s StringValue) Required() StringValue {
+	if errors.Is(s.err, ErrKeyNotFound) || (s.err == nil && s.val == "") {
+		return StringValue{key: s.key, err: fmt.Errorf("web: 缺少参数 %s", s.key)}
+	}
+	return s
+}
+
 func (s StringValue) ToInt64() (int64, error) {
 	if s.err != nil {
 		return 0, s.err
@@ -203,3 +554,93 @@ func (s StringValue) ToInt64() (int64, error) {
 
 	return strconv.ParseInt(s.val, 10, 64)
 }
+
+func (s StringValue) ToInt() (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return strconv.Atoi(s.val)
+}
+
+func (s StringValue) ToUint64() (uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return strconv.ParseUint(s.val, 10, 64)
+}
+
+func (s StringValue) ToUint() (uint, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	val, err := strconv.ParseUint(s.val, 10, strconv.IntSize)
+	return uint(val), err
+}
+
+func (s StringValue) ToFloat64() (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return strconv.ParseFloat(s.val, 64)
+}
+
+// ToBool 支持 1, t, T, TRUE, true, True, 0, f, F, FALSE, false, False
+func (s StringValue) ToBool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+
+	return strconv.ParseBool(s.val)
+}
+
+// ToTime 按照 layout 解析时间，例如 time.RFC3339 或者 "2006-01-02"
+func (s StringValue) ToTime(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+
+	return time.Parse(layout, s.val)
+}
+
+// ToDuration 例如 300ms, 1h30m
+func (s StringValue) ToDuration() (time.Duration, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return time.ParseDuration(s.val)
+}
+
+func (s StringValue) ToUUID() (uuid.UUID, error) {
+	if s.err != nil {
+		return uuid.Nil, s.err
+	}
+
+	return uuid.Parse(s.val)
+}
+
+// countingWriter 记录写入了多少数据，第一次写之前会调用 writeHeader
+type countingWriter struct {
+	w           io.Writer
+	n           int
+	writeHeader func()
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	c.flushHeader()
+	n, err := c.w.Write(data)
+	c.n += n
+	return n, err
+}
+
+// flushHeader 写出状态码，只会写一次
+func (c *countingWriter) flushHeader() {
+	if c.writeHeader != nil {
+		c.writeHeader()
+		c.writeHeader = nil
+	}
+}
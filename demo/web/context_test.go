@@ -0,0 +1,360 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_ClientIP(t *testing.T) {
+	s := NewHTTPServer(ServerWithTrustedProxies("10.0.0.0/8", "192.168.1.1", "2001:db8::/32"))
+	s.Get("/ip", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(ctx.ClientIP())
+	})
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		wantIP     string
+	}{
+		{
+			// 直接连过来的不可信，头部全部忽略
+			name:       "untrusted remote",
+			remoteAddr: "203.0.113.7:1234",
+			header:     map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Real-IP": "2.2.2.2"},
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "no header",
+			remoteAddr: "10.0.0.1:1234",
+			wantIP:     "10.0.0.1",
+		},
+		{
+			// 最左边的可能是伪造的，取最右边不可信的那个
+			name:       "x-forwarded-for chain",
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 192.168.1.1, 10.1.1.1"},
+			wantIP:     "198.51.100.1",
+		},
+		{
+			name:       "x-forwarded-for all trusted",
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "10.2.2.2, 10.1.1.1"},
+			wantIP:     "10.2.2.2",
+		},
+		{
+			name:       "x-forwarded-for invalid",
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "198.51.100.1, abc, 10.1.1.1"},
+			wantIP:     "10.1.1.1",
+		},
+		{
+			// Forwarded 优先
+			name:       "forwarded ipv6",
+			remoteAddr: "[2001:db8::1]:443",
+			header: map[string]string{
+				"Forwarded":       `for="[2001:db8:cafe::17]:4711";proto=https, for=198.51.100.2;by=10.0.0.1`,
+				"X-Forwarded-For": "1.1.1.1",
+			},
+			wantIP: "198.51.100.2",
+		},
+		{
+			name:       "forwarded ipv6 client",
+			remoteAddr: "192.168.1.1:80",
+			header:     map[string]string{"Forwarded": `for="[2400:cb00::1]:4711", for=10.0.0.2`},
+			wantIP:     "2400:cb00::1",
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "192.168.1.1:80",
+			header:     map[string]string{"X-Real-IP": "198.51.100.3"},
+			wantIP:     "198.51.100.3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantIP, recorder.Body.String())
+		})
+	}
+}
+
+func TestServerWithTrustedProxies(t *testing.T) {
+	assert.PanicsWithValue(t, "web: 非法的可信代理 10.0.0.0/33", func() {
+		NewHTTPServer(ServerWithTrustedProxies("10.0.0.0/33"))
+	})
+	assert.PanicsWithValue(t, "web: 非法的可信代理 localhost", func() {
+		NewHTTPServer(ServerWithTrustedProxies("localhost"))
+	})
+}
+
+func TestContext_BindJSON(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+		Age  any    `json:"age"`
+	}
+
+	testCases := []struct {
+		name string
+		opts []HTTPServerOption
+		path string
+		body string
+
+		wantCode int
+		wantUser User
+		wantErr  bool
+	}{
+		{
+			name:     "normal",
+			path:     "/user",
+			body:     `{"name":"Tom","age":18}`,
+			wantCode: http.StatusOK,
+			wantUser: User{Name: "Tom", Age: float64(18)},
+		},
+		{
+			name:     "use number",
+			opts:     []HTTPServerOption{ServerWithJSONDecoder(true, false)},
+			path:     "/user",
+			body:     `{"name":"Tom","age":18}`,
+			wantCode: http.StatusOK,
+			wantUser: User{Name: "Tom", Age: json.Number("18")},
+		},
+		{
+			name:     "unknown field",
+			opts:     []HTTPServerOption{ServerWithJSONDecoder(false, true)},
+			path:     "/user",
+			body:     `{"name":"Tom","email":"tom@example.com"}`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid json",
+			path:     "/user",
+			body:     `{"name":`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  true,
+		},
+		{
+			name:     "too large",
+			opts:     []HTTPServerOption{ServerWithMaxBodySize(8)},
+			path:     "/user",
+			body:     `{"name":"Tom","age":18}`,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantErr:  true,
+		},
+		{
+			// 路由上的限制覆盖全局的
+			name:     "route limit",
+			opts:     []HTTPServerOption{ServerWithMaxBodySize(8)},
+			path:     "/upload",
+			body:     `{"name":"Tom","age":18}`,
+			wantCode: http.StatusOK,
+			wantUser: User{Name: "Tom", Age: float64(18)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(tc.opts...)
+			var user User
+			handler := func(ctx *Context) {
+				err := ctx.BindJSON(&user)
+				assert.Equal(t, tc.wantErr, err != nil)
+				if err == nil {
+					ctx.RespStatusCode = http.StatusOK
+				}
+			}
+			s.Post("/user", handler)
+			s.Use(http.MethodPost, "/upload", MaxBodySize(1024))
+			s.Post("/upload", handler)
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if !tc.wantErr {
+				assert.Equal(t, tc.wantUser, user)
+			}
+		})
+	}
+}
+
+func TestContext_Body(t *testing.T) {
+	// middleware 先读了 body，业务代码依旧可以解析
+	var mdlBody []byte
+	s := NewHTTPServer(ServerWithMaxBodySize(64), ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			var err error
+			mdlBody, err = ctx.Body()
+			if err != nil {
+				return
+			}
+			next(ctx)
+		}
+	}))
+	s.Post("/user", func(ctx *Context) {
+		var user struct {
+			Name string `json:"name"`
+		}
+		require.NoError(t, ctx.BindJSON(&user))
+		body, err := ctx.Body()
+		require.NoError(t, err)
+		// Req.Body 也可以再读一遍
+		raw, err := io.ReadAll(ctx.Req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, raw)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(user.Name)
+	})
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"Tom"}`)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "Tom", recorder.Body.String())
+	assert.Equal(t, `{"name":"Tom"}`, string(mdlBody))
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(strings.Repeat("a", 65))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Equal(t, "请求体过大", recorder.Body.String())
+}
+
+func TestStringValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet,
+		"/user?page=2&size=&price=12.5&admin=true&birthday=2000-01-02&timeout=1m30s"+
+			"&id=b7c5f8a0-2f4e-4d3a-9c1e-1234567890ab&tag=go&tag=web&age=-1", nil)
+	ctx := &Context{Req: req}
+
+	testCases := []struct {
+		name string
+		conv func() (any, error)
+
+		want    any
+		wantErr string
+	}{
+		{
+			name: "int",
+			conv: func() (any, error) { return ctx.QueryValueV2("page").ToInt() },
+			want: 2,
+		},
+		{
+			name: "default empty",
+			conv: func() (any, error) { return ctx.QueryValueV2("size").Default("10").ToInt() },
+			want: 10,
+		},
+		{
+			name: "default missing",
+			conv: func() (any, error) { return ctx.QueryValueV2("order").Default("time").String() },
+			want: "time",
+		},
+		{
+			name:    "missing",
+			conv:    func() (any, error) { return ctx.QueryValueV2("order").String() },
+			want:    "",
+			wantErr: "web: 找不到这个 key",
+		},
+		{
+			name:    "required",
+			conv:    func() (any, error) { return ctx.QueryValueV2("size").Required().ToInt() },
+			want:    0,
+			wantErr: "web: 缺少参数 size",
+		},
+		{
+			name: "uint",
+			conv: func() (any, error) { return ctx.QueryValueV2("page").ToUint() },
+			want: uint(2),
+		},
+		{
+			name:    "uint negative",
+			conv:    func() (any, error) { return ctx.QueryValueV2("age").ToUint() },
+			want:    uint(0),
+			wantErr: `strconv.ParseUint: parsing "-1": invalid syntax`,
+		},
+		{
+			name: "float64",
+			conv: func() (any, error) { return ctx.QueryValueV2("price").ToFloat64() },
+			want: 12.5,
+		},
+		{
+			name: "bool",
+			conv: func() (any, error) { return ctx.QueryValueV2("admin").ToBool() },
+			want: true,
+		},
+		{
+			name: "time",
+			conv: func() (any, error) { return ctx.QueryValueV2("birthday").ToTime("2006-01-02") },
+			want: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "duration",
+			conv: func() (any, error) { return ctx.QueryValueV2("timeout").ToDuration() },
+			want: 90 * time.Second,
+		},
+		{
+			name: "uuid",
+			conv: func() (any, error) { return ctx.QueryValueV2("id").ToUUID() },
+			want: uuid.MustParse("b7c5f8a0-2f4e-4d3a-9c1e-1234567890ab"),
+		},
+		{
+			name: "multi values",
+			conv: func() (any, error) { return ctx.QueryValues("tag") },
+			want: []string{"go", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := tc.conv()
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, val)
+		})
+	}
+}
+
+func TestContext_FormValues(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/user?from=query",
+		strings.NewReader("hobby=go&hobby=web&name=Tom"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := &Context{Req: req}
+
+	hobbies, err := ctx.FormValues("hobby")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "web"}, hobbies)
+
+	name, err := ctx.FormValue("name")
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", name)
+	from, err := ctx.FormValueV2("from").String()
+	require.NoError(t, err)
+	assert.Equal(t, "query", from)
+
+	_, err = ctx.FormValues("age")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// 已经缓存了，即便 Req.Form 被清掉也不会重新解析
+	req.Form = nil
+	name, err = ctx.FormValue("name")
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", name)
+}
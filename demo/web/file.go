@@ -1,15 +1,26 @@
 package web
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	lru "github.com/hashicorp/golang-lru"
+	"html"
 	"io"
+	"io/fs"
 	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type FileUploader struct {
@@ -130,33 +141,71 @@ func (d FileDownloader) Handle() HandleFunc {
 
 type StaticResourceHandlerOption func(h *StaticResourceHandler)
 
+// StaticResourceHandler 静态资源处理器
+// 文件来源是 fs.FS，所以既可以是本地目录（os.DirFS），也可以是 embed.FS
+// 如果 embed.FS 里面的文件带有目录前缀，可以先用 fs.Sub 去掉
 type StaticResourceHandler struct {
-	dir               string
+	fsys fs.FS
+	// 扩展名到 Content-Type 的映射，优先级高于 mime.TypeByExtension
+	// key 带有前导的 .，例如 .pdf
 	extContextTypeMap map[string]string
 
+	// 请求的是目录的时候，依次尝试的首页文件
+	indexFiles []string
+	// 没有首页文件的时候，是否列出目录内容
+	dirListing bool
+	// 是否优先返回预先压缩好的 .br 和 .gz 文件
+	precompressed bool
+
 	// 缓存静态资源的限制
 	cache       *lru.Cache
 	maxFileSize int
 }
 
 type fileCacheItem struct {
-	fileName    string
-	fileSize    int
-	contentType string
-	data        []byte
+	fileName        string
+	fileSize        int
+	contentType     string
+	contentEncoding string
+	// modTime 用于判断文件是否发生了变化，embed.FS 里面的文件是零值
+	modTime time.Time
+	etag    string
+	data    []byte
+	// stream 文件没有读到 data 里面，返回的时候从 fsys 拷贝
+	stream bool
+}
+
+// precompressedFiles 预压缩文件的编码和后缀，按照优先级排列
+var precompressedFiles = []struct {
+	encoding string
+	ext      string
+}{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
 }
 
+// NewStaticResourceHandler 从本地目录 dir 读取静态资源
 func NewStaticResourceHandler(dir string, opts ...StaticResourceHandlerOption) (*StaticResourceHandler, error) {
+	return NewStaticResourceHandlerFS(os.DirFS(dir), opts...)
+}
+
+// NewStaticResourceHandlerFS 从 fsys 读取静态资源，例如 embed.FS
+func NewStaticResourceHandlerFS(fsys fs.FS, opts ...StaticResourceHandlerOption) (*StaticResourceHandler, error) {
+	if fsys == nil {
+		return nil, errors.New("web: fs.FS 为 nil")
+	}
 	res := &StaticResourceHandler{
-		dir: dir,
+		fsys: fsys,
 		extContextTypeMap: map[string]string{
-			// 这里根据自己的需要不断添加
-			"jpeg": "image/jpeg",
-			"jpe":  "image/jpeg",
-			"jpg":  "image/jpeg",
-			"png":  "image/png",
-			"pdf":  "image/pdf",
+			// mime.TypeByExtension 的结果依赖于机器上的 mime 配置
+			// 常用的类型在这里固定下来，根据自己的需要不断添加
+			".jpeg": "image/jpeg",
+			".jpe":  "image/jpeg",
+			".jpg":  "image/jpeg",
+			".png":  "image/png",
+			".pdf":  "application/pdf",
 		},
+		indexFiles: []string{"index.html"},
 	}
 	for _, opt := range opts {
 		opt(res)
@@ -168,25 +217,56 @@ func NewStaticResourceHandler(dir string, opts ...StaticResourceHandlerOption) (
 // WithFileCache 静态文件将会被缓存
 // maxFileSizeThreshold 超过这个大小的文件，就被认为是大文件，我们将不会缓存
 // 所以我们最多缓存 maxFileSizeThreshold * maxCacheFileCnt
+// 每次命中缓存都会比较文件的大小和修改时间，文件变了缓存就会失效
 func WithFileCache(maxFileSizeThreshold int, maxCacheFileCnt int) StaticResourceHandlerOption {
 	return func(h *StaticResourceHandler) {
 		c, err := lru.New(maxCacheFileCnt)
 		if err != nil {
 			log.Printf("创建缓存失败，将不会缓存静态资源")
+			return
 		}
 		h.maxFileSize = maxFileSizeThreshold
 		h.cache = c
 	}
 }
 
+// WithMoreExtension 覆盖或者补充扩展名对应的 Content-Type
+// 扩展名可以带 .，也可以不带，例如 "pdf" 和 ".pdf" 是一样的
 func WithMoreExtension(extMap map[string]string) StaticResourceHandlerOption {
 	return func(h *StaticResourceHandler) {
 		for ext, contentType := range extMap {
-			h.extContextTypeMap[ext] = contentType
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			h.extContextTypeMap[strings.ToLower(ext)] = contentType
 		}
 	}
 }
 
+// WithIndexFiles 请求目录的时候，依次尝试的首页文件，默认是 index.html
+// 不传参数就是关闭首页文件
+func WithIndexFiles(names ...string) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		h.indexFiles = names
+	}
+}
+
+// WithDirListing 目录下没有首页文件的时候，列出目录内容
+// 默认是关闭的，这时候返回 404
+func WithDirListing(enabled bool) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		h.dirListing = enabled
+	}
+}
+
+// WithPrecompressed 如果客户端支持，优先返回同名的 .br 或者 .gz 文件
+// 例如请求 app.js 的时候，会先尝试 app.js.br 再尝试 app.js.gz
+func WithPrecompressed(enabled bool) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		h.precompressed = enabled
+	}
+}
+
 func (h *StaticResourceHandler) Handle(ctx *Context) {
 	// 1. 拿到目标文件名
 	req, err := ctx.PathValue("file")
@@ -195,50 +275,187 @@ func (h *StaticResourceHandler) Handle(ctx *Context) {
 		ctx.RespData = []byte("请求路径不对")
 		return
 	}
-	item, ok := h.readFileFromData(req)
-	if ok {
-		log.Printf("从缓存中读取数据...")
-		h.writeItemAsResponse(item, ctx.Resp)
+	// fs.FS 只接受不带前导 / 的干净路径
+	// 先拼上 / 再 Clean，../ 之类的路径就不可能跳出 fsys
+	name := path.Clean("/" + req)[1:]
+	if name == "" {
+		name = "."
 	}
 
-	// 2. 定位到目标文件，并且读出来
-	path := filepath.Join(h.dir, req)
-	data, err := os.ReadFile(path)
+	// 2. 定位到目标文件
+	fi, err := fs.Stat(h.fsys, name)
 	if err != nil {
-		ctx.RespStatusCode = http.StatusInternalServerError
-		ctx.RespData = []byte("服务器错误")
+		h.writeErr(ctx, err)
+		return
+	}
+	if fi.IsDir() {
+		h.serveDir(ctx, name)
+		return
+	}
+	h.serveFile(ctx, name, fi)
+}
+
+func (h *StaticResourceHandler) serveFile(ctx *Context, name string, fi fs.FileInfo) {
+	item, err := h.loadFile(name, fi, ctx.Req.Header.Get("Accept-Encoding"))
+	if err != nil {
+		h.writeErr(ctx, err)
 		return
 	}
 	// 3. 返回给前端
-	ext := getFileExt(path)
-	t, ok := h.extContextTypeMap[ext]
-	if !ok {
-		ctx.RespStatusCode = http.StatusBadRequest
+	h.writeItemAsResponse(item, ctx)
+}
+
+func (h *StaticResourceHandler) serveDir(ctx *Context, name string) {
+	for _, index := range h.indexFiles {
+		indexName := path.Join(name, index)
+		fi, err := fs.Stat(h.fsys, indexName)
+		if err == nil && !fi.IsDir() {
+			h.serveFile(ctx, indexName, fi)
+			return
+		}
+	}
+
+	if !h.dirListing {
+		ctx.RespStatusCode = http.StatusNotFound
+		ctx.RespData = []byte("NOT FOUND")
 		return
 	}
-	item = &fileCacheItem{
-		fileSize:    len(data),
-		data:        data,
-		contentType: t,
-		fileName:    req,
+
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		h.writeErr(ctx, err)
+		return
 	}
-	h.cacheFile(item)
-	h.writeItemAsResponse(item, ctx.Resp)
+	base := strings.TrimSuffix(ctx.Req.URL.Path, "/")
+	buf := &bytes.Buffer{}
+	buf.WriteString("<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: base + "/" + entryName}
+		fmt.Fprintf(buf, "<a href=\"%s\">%s</a>\n",
+			html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+
+	ctx.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.RespStatusCode = http.StatusOK
+	ctx.RespData = buf.Bytes()
 }
 
-func getFileExt(name string) string {
-	ext := filepath.Ext(name)
-	return ext[1:]
+// loadFile 找到应该返回的文件，如果开启了预压缩，优先找压缩过的版本
+func (h *StaticResourceHandler) loadFile(name string, fi fs.FileInfo, acceptEncoding string) (*fileCacheItem, error) {
+	contentType := h.contentType(name)
+	if h.precompressed {
+		for _, pf := range precompressedFiles {
+			if !acceptsEncoding(acceptEncoding, pf.encoding) {
+				continue
+			}
+			cfi, err := fs.Stat(h.fsys, name+pf.ext)
+			if err != nil || cfi.IsDir() {
+				continue
+			}
+			if contentType == "" {
+				// 压缩过的数据没有办法探测类型
+				contentType = "application/octet-stream"
+			}
+			return h.readFile(name+pf.ext, cfi, contentType, pf.encoding)
+		}
+	}
+	return h.readFile(name, fi, contentType, "")
 }
 
-func (h *StaticResourceHandler) readFileFromData(fileName string) (*fileCacheItem, bool) {
-	if h.cache != nil {
-		if item, ok := h.cache.Get(fileName); ok {
-			return item.(*fileCacheItem), true
+func (h *StaticResourceHandler) readFile(name string, fi fs.FileInfo,
+	contentType string, encoding string) (*fileCacheItem, error) {
+	if item, ok := h.readFileFromCache(name, fi); ok {
+		return item, nil
+	}
+
+	item := &fileCacheItem{
+		fileName:        name,
+		fileSize:        int(fi.Size()),
+		contentType:     contentType,
+		contentEncoding: encoding,
+		modTime:         fi.ModTime(),
+	}
+	// 不会被缓存的文件不读到内存里，返回的时候直接从 fsys 拷贝
+	if h.cache == nil || item.fileSize >= h.maxFileSize {
+		item.stream = true
+		if err := h.scanFile(item); err != nil {
+			return nil, err
 		}
+		return item, nil
 	}
 
-	return nil, false
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if item.contentType == "" {
+		item.contentType = http.DetectContentType(data)
+	}
+	item.etag, err = newETag(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	item.fileSize = len(data)
+	item.data = data
+	h.cacheFile(item)
+	return item, nil
+}
+
+// scanFile 读一遍文件，计算 ETag，需要的话顺便探测 Content-Type
+func (h *StaticResourceHandler) scanFile(item *fileCacheItem) error {
+	f, err := h.fsys.Open(item.fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if item.contentType == "" {
+		// http.DetectContentType 最多只看前 512 个字节
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		item.contentType = http.DetectContentType(head[:n])
+		r = io.MultiReader(bytes.NewReader(head[:n]), f)
+	}
+	item.etag, err = newETag(r)
+	return err
+}
+
+// contentType 根据扩展名推断 Content-Type，推断不出来就返回空字符串
+func (h *StaticResourceHandler) contentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if t, ok := h.extContextTypeMap[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+func (h *StaticResourceHandler) readFileFromCache(fileName string, fi fs.FileInfo) (*fileCacheItem, bool) {
+	if h.cache == nil {
+		return nil, false
+	}
+	val, ok := h.cache.Get(fileName)
+	if !ok {
+		return nil, false
+	}
+	item := val.(*fileCacheItem)
+	// 文件被修改过了，缓存失效
+	if item.fileSize != int(fi.Size()) || !item.modTime.Equal(fi.ModTime()) {
+		h.cache.Remove(fileName)
+		return nil, false
+	}
+	return item, true
 }
 
 func (h *StaticResourceHandler) cacheFile(item *fileCacheItem) {
@@ -247,9 +464,131 @@ func (h *StaticResourceHandler) cacheFile(item *fileCacheItem) {
 	}
 }
 
-func (h *StaticResourceHandler) writeItemAsResponse(item *fileCacheItem, writer http.ResponseWriter) {
-	writer.WriteHeader(http.StatusOK)
-	writer.Header().Set("Content-Type", item.contentType)
-	writer.Header().Set("Content-Type", strconv.Itoa(item.fileSize))
-	_, _ = writer.Write(item.data)
+func (h *StaticResourceHandler) writeItemAsResponse(item *fileCacheItem, ctx *Context) {
+	header := ctx.Resp.Header()
+	if h.precompressed {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	header.Set("ETag", item.etag)
+	if !item.modTime.IsZero() {
+		header.Set("Last-Modified", item.modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(ctx.Req, item) {
+		ctx.RespStatusCode = http.StatusNotModified
+		ctx.RespData = nil
+		return
+	}
+
+	if item.stream {
+		h.streamFile(item, ctx)
+		return
+	}
+
+	h.setContentHeaders(item, header)
+	ctx.RespStatusCode = http.StatusOK
+	ctx.RespData = item.data
+}
+
+// streamFile 直接把文件拷贝到 Resp 里面，不经过 RespData
+func (h *StaticResourceHandler) streamFile(item *fileCacheItem, ctx *Context) {
+	f, err := h.fsys.Open(item.fileName)
+	if err != nil {
+		h.writeErr(ctx, err)
+		return
+	}
+	defer f.Close()
+
+	h.setContentHeaders(item, ctx.Resp.Header())
+	ctx.Resp.WriteHeader(http.StatusOK)
+	ctx.RespStatusCode = http.StatusOK
+	ctx.respFlushed = true
+	if ctx.Req.Method == http.MethodHead {
+		return
+	}
+	// 已经写了 Content-Length，文件在这期间变大了也只写这么多
+	_, _ = io.CopyN(ctx.Resp, f, int64(item.fileSize))
+}
+
+func (h *StaticResourceHandler) setContentHeaders(item *fileCacheItem, header http.Header) {
+	header.Set("Content-Type", item.contentType)
+	header.Set("Content-Length", strconv.Itoa(item.fileSize))
+	if item.contentEncoding != "" {
+		header.Set("Content-Encoding", item.contentEncoding)
+	}
+}
+
+func (h *StaticResourceHandler) writeErr(ctx *Context, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		ctx.RespStatusCode = http.StatusNotFound
+		ctx.RespData = []byte("NOT FOUND")
+	case errors.Is(err, fs.ErrPermission):
+		ctx.RespStatusCode = http.StatusForbidden
+		ctx.RespData = []byte("没有权限")
+	default:
+		ctx.RespStatusCode = http.StatusInternalServerError
+		ctx.RespData = []byte("服务器错误")
+	}
+}
+
+// notModified 判断是否可以直接返回 304
+// 同时有 If-None-Match 和 If-Modified-Since 的时候，以 If-None-Match 为准
+func notModified(req *http.Request, item *fileCacheItem) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, item.etag)
+	}
+
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" || item.modTime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP 的时间只精确到秒
+	return !item.modTime.Truncate(time.Second).After(t)
+}
+
+// etagMatch If-None-Match 使用弱比较，W/ 前缀不影响结果
+func etagMatch(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// newETag 边读边计算 sha256，大文件也不需要整个读到内存里
+func newETag(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16]), nil
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受 encoding，q=0 代表明确拒绝
+func acceptsEncoding(header string, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		params = strings.TrimSpace(params)
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
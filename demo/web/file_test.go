@@ -0,0 +1,248 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticResourceHandler_Serve(t *testing.T) {
+	modTime := time.Date(2023, 7, 1, 8, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"myjs.js":         {Data: []byte("console.log('hello')"), ModTime: modTime},
+		"myjs.js.br":      {Data: []byte("br data"), ModTime: modTime},
+		"myjs.js.gz":      {Data: []byte("gzip data"), ModTime: modTime},
+		"doc.pdf":         {Data: []byte("%PDF-1.4"), ModTime: modTime},
+		"LICENSE":         {Data: []byte("plain text license"), ModTime: modTime},
+		"site/index.html": {Data: []byte("<html>site</html>"), ModTime: modTime},
+		"assets/a.png":    {Data: []byte("png"), ModTime: modTime},
+		"assets/b.css":    {Data: []byte("css"), ModTime: modTime},
+	}
+	myjsETag, err := newETag(strings.NewReader("console.log('hello')"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		opts    []StaticResourceHandlerOption
+		path    string
+		headers map[string]string
+
+		wantCode    int
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{
+			name:     "mime type by extension",
+			path:     "/static/myjs.js",
+			wantCode: http.StatusOK,
+			wantBody: "console.log('hello')",
+			wantHeaders: map[string]string{
+				"Content-Type":   "text/javascript; charset=utf-8",
+				"Content-Length": "20",
+				"Last-Modified":  modTime.Format(http.TimeFormat),
+			},
+		},
+		{
+			name:        "pdf override",
+			path:        "/static/doc.pdf",
+			wantCode:    http.StatusOK,
+			wantBody:    "%PDF-1.4",
+			wantHeaders: map[string]string{"Content-Type": "application/pdf"},
+		},
+		{
+			name:        "no extension",
+			path:        "/static/LICENSE",
+			wantCode:    http.StatusOK,
+			wantBody:    "plain text license",
+			wantHeaders: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		},
+		{
+			name:     "not found",
+			path:     "/static/abc.js",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:     "escape root",
+			path:     "/static/..",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:     "if-modified-since",
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "if-modified-since expired",
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			wantCode: http.StatusOK,
+			wantBody: "console.log('hello')",
+		},
+		{
+			name:     "if-none-match",
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"If-None-Match": `"abc", ` + myjsETag},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name: "if-none-match wins",
+			path: "/static/myjs.js",
+			headers: map[string]string{
+				"If-None-Match":     `"abc"`,
+				"If-Modified-Since": modTime.Format(http.TimeFormat),
+			},
+			wantCode: http.StatusOK,
+			wantBody: "console.log('hello')",
+		},
+		{
+			name:     "precompressed br",
+			opts:     []StaticResourceHandlerOption{WithPrecompressed(true)},
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"Accept-Encoding": "gzip, br"},
+			wantCode: http.StatusOK,
+			wantBody: "br data",
+			wantHeaders: map[string]string{
+				"Content-Type":     "text/javascript; charset=utf-8",
+				"Content-Encoding": "br",
+				"Vary":             "Accept-Encoding",
+			},
+		},
+		{
+			name:     "precompressed gzip",
+			opts:     []StaticResourceHandlerOption{WithPrecompressed(true)},
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"Accept-Encoding": "gzip, br;q=0"},
+			wantCode: http.StatusOK,
+			wantBody: "gzip data",
+			wantHeaders: map[string]string{
+				"Content-Encoding": "gzip",
+			},
+		},
+		{
+			name:     "precompressed disabled",
+			path:     "/static/myjs.js",
+			headers:  map[string]string{"Accept-Encoding": "gzip, br"},
+			wantCode: http.StatusOK,
+			wantBody: "console.log('hello')",
+		},
+		{
+			name:     "index file",
+			path:     "/static/site",
+			wantCode: http.StatusOK,
+			wantBody: "<html>site</html>",
+		},
+		{
+			name:     "index file disabled",
+			opts:     []StaticResourceHandlerOption{WithIndexFiles()},
+			path:     "/static/site",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:     "dir listing disabled",
+			path:     "/static/assets",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:     "dir listing",
+			opts:     []StaticResourceHandlerOption{WithDirListing(true)},
+			path:     "/static/assets",
+			wantCode: http.StatusOK,
+			wantBody: "<pre>\n<a href=\"/static/assets/a.png\">a.png</a>\n" +
+				"<a href=\"/static/assets/b.css\">b.css</a>\n</pre>\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := NewStaticResourceHandlerFS(fsys, tc.opts...)
+			require.NoError(t, err)
+			s := NewHTTPServer()
+			s.Get("/static/:file", h.Handle)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			for k, v := range tc.wantHeaders {
+				assert.Equal(t, v, recorder.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestStaticResourceHandler_CacheInvalidation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("v1"), ModTime: time.Unix(100, 0)},
+	}
+	h, err := NewStaticResourceHandlerFS(fsys, WithFileCache(1024, 10))
+	require.NoError(t, err)
+	s := NewHTTPServer()
+	s.Get("/static/:file", h.Handle)
+
+	get := func() string {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/a.txt", nil))
+		return recorder.Body.String()
+	}
+
+	assert.Equal(t, "v1", get())
+	_, ok := h.cache.Get("a.txt")
+	assert.True(t, ok)
+
+	// 大小没变，修改时间变了
+	fsys["a.txt"] = &fstest.MapFile{Data: []byte("v2"), ModTime: time.Unix(200, 0)}
+	assert.Equal(t, "v2", get())
+}
+
+func TestStaticResourceHandler_LargeFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"small.txt": {Data: []byte("small"), ModTime: time.Unix(100, 0)},
+		"large.txt": {Data: []byte(strings.Repeat("a", 1024)), ModTime: time.Unix(100, 0)},
+	}
+	h, err := NewStaticResourceHandlerFS(fsys, WithFileCache(16, 10))
+	require.NoError(t, err)
+	s := NewHTTPServer()
+	s.Get("/static/:file", h.Handle)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/large.txt", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, strings.Repeat("a", 1024), recorder.Body.String())
+	assert.Equal(t, "1024", recorder.Header().Get("Content-Length"))
+	assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+	etag := recorder.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// 超过大小限制的文件不缓存
+	_, ok := h.cache.Get("large.txt")
+	assert.False(t, ok)
+
+	recorder = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/static/large.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/small.txt", nil))
+	assert.Equal(t, "small", recorder.Body.String())
+	_, ok = h.cache.Get("small.txt")
+	assert.True(t, ok)
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HostRouter 某个域名专属的路由树和 middleware
+// 没有匹配上任何 HostRouter 的请求，使用 HTTPServer 本身的路由，也就是默认域名
+type HostRouter struct {
+	router
+	pattern string
+	// labels 按照 . 切割之后的 pattern，通配符域名不需要
+	labels []string
+	mdls   []Middleware
+}
+
+// Host 返回 pattern 对应的 HostRouter，同一个 pattern 多次调用返回同一个，mdls 会追加上去
+// pattern 支持三种形式，匹配的优先级也是这个顺序：
+// - 精确匹配：api.example.com
+// - 域名参数：:tenant.example.com，通过 Context.HostValue("tenant") 获取
+// - 通配符：*.example.com，匹配 example.com 的任意子域名（不包括 example.com 本身），
+// 多个通配符都能匹配的时候，后缀最长的优先
+// mdls 只作用于这个域名，在 ServerWithMiddleware 注册的 middleware 之后执行
+func (s *HTTPServer) Host(pattern string, mdls ...Middleware) *HostRouter {
+	pattern = strings.ToLower(pattern)
+	if h, ok := s.hosts[pattern]; ok {
+		h.mdls = append(h.mdls, mdls...)
+		return h
+	}
+	if !validHostPattern(pattern) {
+		panic(fmt.Sprintf("web: 非法的域名 %s", pattern))
+	}
+
+	h := &HostRouter{router: newRouter(), pattern: pattern, mdls: mdls}
+	if s.hosts == nil {
+		s.hosts = make(map[string]*HostRouter, 4)
+	}
+	s.hosts[pattern] = h
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		s.hostWildcards = append(s.hostWildcards, h)
+		// 后缀越长越具体
+		sort.SliceStable(s.hostWildcards, func(i, j int) bool {
+			return len(s.hostWildcards[i].pattern) > len(s.hostWildcards[j].pattern)
+		})
+	case strings.Contains(pattern, ":"):
+		h.labels = strings.Split(pattern, ".")
+		s.hostParams = append(s.hostParams, h)
+	}
+	return h
+}
+
+func validHostPattern(pattern string) bool {
+	if pattern == "" || strings.HasSuffix(pattern, ".") {
+		return false
+	}
+	for i, label := range strings.Split(pattern, ".") {
+		switch {
+		case label == "":
+			return false
+		case label == "*":
+			if i != 0 {
+				return false
+			}
+		case label[0] == ':':
+			if len(label) == 1 {
+				return false
+			}
+		case strings.ContainsAny(label, ":*/"):
+			return false
+		}
+	}
+	return true
+}
+
+func (h *HostRouter) Get(path string, handleFunc HandleFunc) {
+	h.addRoute(http.MethodGet, path, handleFunc)
+}
+
+func (h *HostRouter) Post(path string, handleFunc HandleFunc) {
+	h.addRoute(http.MethodPost, path, handleFunc)
+}
+
+// Use 和 HTTPServer.Use 一样，只作用于这个域名下面的路由
+func (h *HostRouter) Use(method string, path string, mdls ...Middleware) {
+	h.addRoute(method, path, nil, mdls...)
+}
+
+// TryAddRoute 和 HTTPServer.TryAddRoute 一样
+func (h *HostRouter) TryAddRoute(method string, path string, handleFunc HandleFunc, mdls ...Middleware) error {
+	if err := h.tryAddRoute(method, path, handleFunc, mdls...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// matchHost 找到请求的 Host 对应的 HostRouter，没有就返回 nil
+func (s *HTTPServer) matchHost(host string) (*HostRouter, map[string]string) {
+	if len(s.hosts) == 0 {
+		return nil, nil
+	}
+	host = normalizeHost(host)
+	if h, ok := s.hosts[host]; ok && h.labels == nil && !strings.HasPrefix(h.pattern, "*.") {
+		return h, nil
+	}
+
+	labels := strings.Split(host, ".")
+	for _, h := range s.hostParams {
+		if params, ok := h.matchLabels(labels); ok {
+			return h, params
+		}
+	}
+	for _, h := range s.hostWildcards {
+		suffix := h.pattern[1:]
+		if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *HostRouter) matchLabels(labels []string) (map[string]string, bool) {
+	if len(labels) != len(h.labels) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, label := range h.labels {
+		if label[0] == ':' {
+			if params == nil {
+				params = make(map[string]string, 1)
+			}
+			params[label[1:]] = labels[i]
+			continue
+		}
+		if label != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// normalizeHost 去掉端口和末尾的 .，统一成小写
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
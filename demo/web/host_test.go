@@ -0,0 +1,129 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer_Host(t *testing.T) {
+	s := NewHTTPServer()
+	respond := func(name string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespStatusCode = http.StatusOK
+			ctx.RespData = append(ctx.RespData, name...)
+		}
+	}
+	hostMdl := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespData = append(ctx.RespData, "mdl:"...)
+			next(ctx)
+		}
+	}
+
+	s.Get("/", respond("default"))
+	s.Host("api.example.com").Get("/", respond("api"))
+	s.Host("*.example.com", hostMdl).Get("/", respond("wildcard"))
+	s.Host("*.eu.example.com").Get("/", respond("eu"))
+	s.Host(":tenant.shop.com").Get("/user/:id", func(ctx *Context) {
+		tenant, _ := ctx.HostValue("tenant").String()
+		id, _ := ctx.PathValue("id")
+		respond("tenant:" + tenant + ",user:" + id)(ctx)
+	})
+
+	testCases := []struct {
+		name string
+		host string
+		path string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "exact",
+			host:     "api.example.com",
+			path:     "/",
+			wantCode: http.StatusOK,
+			wantBody: "api",
+		},
+		{
+			name:     "exact with port and upper case",
+			host:     "API.example.com:8080",
+			path:     "/",
+			wantCode: http.StatusOK,
+			wantBody: "api",
+		},
+		{
+			name:     "wildcard",
+			host:     "www.example.com",
+			path:     "/",
+			wantCode: http.StatusOK,
+			wantBody: "mdl:wildcard",
+		},
+		{
+			name:     "longest wildcard",
+			host:     "paris.eu.example.com",
+			path:     "/",
+			wantCode: http.StatusOK,
+			wantBody: "eu",
+		},
+		{
+			// 通配符不匹配 example.com 本身
+			name:     "default",
+			host:     "example.com",
+			path:     "/",
+			wantCode: http.StatusOK,
+			wantBody: "default",
+		},
+		{
+			name:     "host param",
+			host:     "acme.shop.com",
+			path:     "/user/12",
+			wantCode: http.StatusOK,
+			wantBody: "tenant:acme,user:12",
+		},
+		{
+			// 域名匹配上了，但是路由没有，依旧是 404，host middleware 也会执行
+			name:     "host route not found",
+			host:     "www.example.com",
+			path:     "/user",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Host = tc.host
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+
+	// 同一个 pattern 返回同一个 HostRouter
+	assert.Same(t, s.Host("API.example.com"), s.Host("api.example.com"))
+
+	routes := s.Routes()
+	require.Len(t, routes, 5)
+	assert.Equal(t, "", routes[0].Host)
+	assert.Equal(t, "*.eu.example.com", routes[1].Host)
+	assert.Equal(t, ":tenant.shop.com", routes[3].Host)
+	assert.Equal(t, "/user/:id", routes[3].Pattern)
+
+	var sb strings.Builder
+	require.NoError(t, s.DumpTree(&sb))
+	assert.Contains(t, sb.String(), "\nHost *.example.com\nGET / -> ")
+
+	for _, pattern := range []string{"", "a..com", "www.*.com", ":.com", "example.com."} {
+		assert.Panics(t, func() {
+			s.Host(pattern)
+		}, pattern)
+	}
+}
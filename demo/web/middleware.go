@@ -1,9 +1,109 @@
 package web
 
+import "net/http"
+
 // Middleware 函数式的责任链模式
 // 函数式的洋葱模式
 type Middleware func(next HandleFunc) HandleFunc
 
+// MaxBodySize 限制单个路由的请求体大小，覆盖 ServerWithMaxBodySize
+// 例如 server.Use(http.MethodPost, "/upload", MaxBodySize(32<<20))
+func MaxBodySize(n int64) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.SetMaxBodySize(n)
+			next(ctx)
+		}
+	}
+}
+
+// WrapHTTPMiddleware 把标准库风格的 func(http.Handler) http.Handler 转成 Middleware
+// 因为外面的 middleware 可能会包装 ResponseWriter（例如压缩），
+// 所以 next 执行完之后会立刻把 RespData 写进包装过的 ResponseWriter，
+// 在它之前的 Middleware 就不能再修改响应了，只能拿到 RespStatusCode
+func WrapHTTPMiddleware(m func(http.Handler) http.Handler) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			resp := ctx.Resp
+			w := &statusWriter{ResponseWriter: resp}
+			m(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+				ctx.Req, ctx.Resp = req, writer
+				next(ctx)
+				_ = ctx.flushTo(writer)
+			})).ServeHTTP(w, ctx.Req)
+			ctx.Resp = resp
+			// 外面的 middleware 没有调用 next，自己写了响应，例如鉴权失败
+			if !ctx.respFlushed && w.wroteHeader {
+				ctx.respFlushed = true
+			}
+			if ctx.respFlushed {
+				ctx.RespStatusCode = w.statusCode()
+			}
+		}
+	}
+}
+
+// ToHTTPMiddleware 把 Middleware 转成标准库风格的 func(http.Handler) http.Handler
+// 依赖路由的功能，例如 MatchedRoute 和 PathParams，在这种情况下是没有的
+func ToHTTPMiddleware(m Middleware) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			ctx := &Context{Req: req, Resp: writer}
+			m(func(ctx *Context) {
+				w := &statusWriter{ResponseWriter: ctx.Resp}
+				next.ServeHTTP(w, ctx.Req)
+				ctx.RespStatusCode = w.statusCode()
+				ctx.respFlushed = true
+			})(ctx)
+			// Middleware 自己设置了响应，没有调用 next
+			_ = ctx.flushTo(ctx.Resp)
+		})
+	}
+}
+
+// statusWriter 记录下游写的状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.status = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush 流式响应，例如 pprof 的 trace 需要
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 给 http.ResponseController 使用
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode 下游什么都没写的话，net/http 默认返回 200
+func (w *statusWriter) statusCode() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+	return w.status
+}
+
 // AOP 方案在不同的框架，不同的语言里面都有不同的叫法
 // Middleware, Handler, Chain, Filter, Filter-Chain
 // Interceptor, Wrapper
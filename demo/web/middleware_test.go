@@ -0,0 +1,80 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapHTTPMiddleware(t *testing.T) {
+	// 标准库风格的 middleware，包装 ResponseWriter 并且可能直接拒绝请求
+	stdMdl := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-Std", "1")
+			next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), "req-1")))
+		})
+	}
+
+	var status int
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			status = ctx.RespStatusCode
+		}
+	}, WrapHTTPMiddleware(stdMdl)))
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusCreated
+		ctx.RespData = []byte(ctx.RequestID())
+	})
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "unauthorized\n", recorder.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "req-1", recorder.Body.String())
+	assert.Equal(t, "1", recorder.Header().Get("X-Std"))
+	assert.Equal(t, http.StatusCreated, status)
+}
+
+func TestToHTTPMiddleware(t *testing.T) {
+	var status int
+	mdl := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if ctx.Req.URL.Query().Get("deny") != "" {
+				ctx.RespStatusCode = http.StatusForbidden
+				ctx.RespData = []byte("denied")
+				return
+			}
+			next(ctx)
+			status = ctx.RespStatusCode
+		}
+	}
+	h := ToHTTPMiddleware(mdl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, "ok", recorder.Body.String())
+	assert.Equal(t, http.StatusAccepted, status)
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?deny=1", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, "denied", recorder.Body.String())
+}
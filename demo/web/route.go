@@ -38,18 +38,27 @@ func newRouter() router {
 // 定义为私有的 addRoute
 // 1. 用户只能通过 Get 或者 Post来注册，那么可以确保 method 参数永远是对的
 // 2. addRoute 在接口里面是私有的，限制了用户将无法实现 Server。
+// 非法路由直接 panic，不想 panic 的话使用 tryAddRoute
 func (r *router) addRoute(method string, path string, handleFunc HandleFunc, mdls ...Middleware) {
+	if err := r.tryAddRoute(method, path, handleFunc, mdls...); err != nil {
+		panic(err.msg)
+	}
+}
+
+// tryAddRoute 和 addRoute 的规则一样，但是非法路由会返回 *RouteError
+// 出错的时候，路径上已经创建的静态节点不会被删除，不过它们没有 handler，不影响路由匹配
+func (r *router) tryAddRoute(method string, path string, handleFunc HandleFunc, mdls ...Middleware) *RouteError {
 	// 对 path 进行校验
 	if path == "" {
-		panic("web: 路由是空字符串")
+		return newRouteError(method, path, "", "web: 路由是空字符串")
 	}
 
 	if path[0] != '/' {
-		panic("web: 路径必须以 / 开头")
+		return newRouteError(method, path, "", "web: 路径必须以 / 开头")
 	}
 
 	if path != "/" && path[len(path)-1] == '/' {
-		panic("web: 路径不能以 / 结尾")
+		return newRouteError(method, path, "", "web: 路径不能以 / 结尾")
 	}
 	// 首先找到树来
 	root, ok := r.trees[method]
@@ -63,13 +72,19 @@ func (r *router) addRoute(method string, path string, handleFunc HandleFunc, mdl
 
 	// 根节点特殊处理一下
 	if path == "/" {
+		// 只注册 middleware 的时候，不需要检测冲突
+		if handleFunc == nil {
+			root.matchedMdls = append(root.matchedMdls, mdls...)
+			root.route = path
+			return nil
+		}
 		if root.handler != nil {
-			panic("web: 路由冲突[/]")
+			return newRouteError(method, path, root.route, "web: 路由冲突[/]")
 		}
-		root.matchedMdls = mdls
+		root.matchedMdls = append(root.matchedMdls, mdls...)
 		root.handler = handleFunc
 		root.route = path
-		return
+		return nil
 	}
 
 	// 切割这个 path
@@ -77,18 +92,73 @@ func (r *router) addRoute(method string, path string, handleFunc HandleFunc, mdl
 	// 开始一段段处理
 	for _, seg := range segs {
 		if seg == "" {
-			panic(fmt.Sprintf("web: 非法路由。不允许使用 //a/b, /a//b 之类的路由,[%s]", path))
+			return newRouteError(method, path, "",
+				fmt.Sprintf("web: 非法路由。不允许使用 //a/b, /a//b 之类的路由,[%s]", path))
 		}
 		// 递归下去，找准位置
 		// 如果中途有节点不存在，你就要创建出来
-		root = root.childOrCreate(seg)
+		child, err := root.childOrCreate(seg)
+		if err != nil {
+			err.Method, err.Route = method, path
+			return err
+		}
+		root = child
+	}
+	// Use 和 Get 之类的方法可以先后注册到同一个节点上
+	if handleFunc == nil {
+		root.matchedMdls = append(root.matchedMdls, mdls...)
+		root.route = path
+		return nil
 	}
 	if root.handler != nil {
-		panic(fmt.Sprintf("web: 路由冲突，重复注册[%s]", path))
+		return newRouteError(method, path, root.route, fmt.Sprintf("web: 路由冲突，重复注册[%s]", path))
 	}
 	root.handler = handleFunc
 	root.route = path
-	root.matchedMdls = mdls
+	root.matchedMdls = append(root.matchedMdls, mdls...)
+	return nil
+}
+
+// RouteError 注册路由失败
+type RouteError struct {
+	Method string
+	// Route 新注册的路由
+	Route string
+	// Existing 和新路由冲突的已有路由，路由本身不合法的时候为空
+	Existing string
+	msg      string
+}
+
+func newRouteError(method string, route string, existing string, msg string) *RouteError {
+	return &RouteError{Method: method, Route: route, Existing: existing, msg: msg}
+}
+
+func (e *RouteError) Error() string {
+	if e.Existing == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Route, e.msg)
+	}
+	return fmt.Sprintf("%s %s 和已有路由 %s 冲突: %s", e.Method, e.Route, e.Existing, e.msg)
+}
+
+// RouteErrors RouteBuilder.Build 收集到的所有错误
+type RouteErrors []*RouteError
+
+func (e RouteErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "web: 注册路由失败，共 %d 个错误", len(e))
+	for _, err := range e {
+		sb.WriteString("\n\t")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (e RouteErrors) Unwrap() []error {
+	res := make([]error, 0, len(e))
+	for _, err := range e {
+		res = append(res, err)
+	}
+	return res
 }
 
 // findRoute 查找对应的节点
@@ -143,7 +213,7 @@ func (r *router) findMdls(root *node, segs []string) []Middleware {
 			if root.paramChild != nil {
 				children = append(children, root.paramChild)
 			}
-			if root.regChild != nil && root.regExpr.MatchString(seg) {
+			if root.regChild != nil && root.regChild.regExpr.MatchString(seg) {
 				children = append(children, root.regChild)
 			}
 			if root.children != nil {
@@ -219,23 +289,25 @@ type node struct {
 // 其次判断 path 是不是参数路径，即以 : 开头的路径
 // 最后会从 children 里面查找，
 // 如果没有找到，那么会创建一个新的节点，并且保存在 node 里面
-func (n *node) childOrCreate(seg string) *node {
+func (n *node) childOrCreate(seg string) (*node, *RouteError) {
 	childNode := &node{
 		path: seg,
 	}
 
 	if seg == "*" {
 		if n.paramChild != nil {
-			panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册通配符路由和参数路由 [%s]", seg))
+			return nil, n.conflict(n.paramChild,
+				fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册通配符路由和参数路由 [%s]", seg))
 		}
 		if n.regChild != nil {
-			panic(fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册通配符路由和正则路由 [%s]", seg))
+			return nil, n.conflict(n.regChild,
+				fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册通配符路由和正则路由 [%s]", seg))
 		}
 		if n.starChild == nil {
 			childNode.typ = nodeTypeAny
 			n.starChild = childNode
 		}
-		return n.starChild
+		return n.starChild, nil
 	}
 
 	// 以 : 开头，需要进一步解析，判断是参数路由还是正则路由
@@ -258,46 +330,70 @@ func (n *node) childOrCreate(seg string) *node {
 		n.children[seg] = res
 	}
 
-	return res
+	return res, nil
 }
 
-func (n *node) childOrCreateParam(path string, paramName string) *node {
+func (n *node) childOrCreateParam(path string, paramName string) (*node, *RouteError) {
 	if n.starChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [%s]", path))
+		return nil, n.conflict(n.starChild,
+			fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [%s]", path))
 	}
 	if n.regChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册正则路由和参数路由 [%s]", path))
+		return nil, n.conflict(n.regChild,
+			fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册正则路由和参数路由 [%s]", path))
 	}
 	if n.paramChild == nil {
 		n.paramChild = &node{path: path, paramName: paramName, typ: nodeTypeParam}
 	} else {
 		if n.paramChild.path != path {
-			panic(fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.paramChild.path, path))
+			return nil, n.conflict(n.paramChild,
+				fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.paramChild.path, path))
 		}
 	}
-	return n.paramChild
+	return n.paramChild, nil
 }
 
-func (n *node) childOrCreateReg(path string, expr string, paramName string) *node {
+func (n *node) childOrCreateReg(path string, expr string, paramName string) (*node, *RouteError) {
 	if n.starChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和正则路由 [%s]", path))
+		return nil, n.conflict(n.starChild,
+			fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和正则路由 [%s]", path))
 	}
 	if n.paramChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册正则路由和参数路由 [%s]", path))
+		return nil, n.conflict(n.paramChild,
+			fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册正则路由和参数路由 [%s]", path))
 	}
 	if n.regChild == nil {
 		regExr, err := regexp.Compile(expr)
 		if err != nil {
-			panic(fmt.Errorf("web: 正则表达式错误 %w", err))
+			return nil, &RouteError{msg: fmt.Sprintf("web: 正则表达式错误 %s", err)}
 		}
 		n.regChild = &node{path: path, paramName: paramName, regExpr: regExr, typ: nodeTypeReg}
 	} else {
 		// :id() :name()
 		if n.regChild.regExpr.String() != expr || n.regChild.paramName != paramName {
-			panic(fmt.Sprintf("web: 路由冲突，正则路由冲突，已有 %s，新注册 %s", n.paramChild.path, path))
+			return nil, n.conflict(n.regChild,
+				fmt.Sprintf("web: 路由冲突，正则路由冲突，已有 %s，新注册 %s", n.regChild.path, path))
+		}
+	}
+	return n.regChild, nil
+}
+
+// conflict 和 existing 这棵子树上已经注册的路由冲突
+func (n *node) conflict(existing *node, msg string) *RouteError {
+	return &RouteError{Existing: existing.anyRoute(), msg: msg}
+}
+
+// anyRoute 子树上任意一个注册过的路由，用来在冲突的时候告诉用户和谁冲突了
+func (n *node) anyRoute() string {
+	if n.route != "" {
+		return n.route
+	}
+	for _, child := range n.sortedChildren() {
+		if route := child.anyRoute(); route != "" {
+			return route
 		}
 	}
-	return n.regChild
+	return ""
 }
 
 // parseParam 用于解析判断是不是正则表达式
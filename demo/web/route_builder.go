@@ -0,0 +1,67 @@
+package web
+
+import "net/http"
+
+// RouteBuilder 先收集路由，Build 的时候一次性校验并注册
+// 适合从配置文件之类的地方加载路由：所有的错误都会被收集起来一起返回，
+// 并且只要有一个路由有问题，就一个都不会注册
+type RouteBuilder struct {
+	server *HTTPServer
+	routes []routeDef
+}
+
+type routeDef struct {
+	method  string
+	path    string
+	handler HandleFunc
+	mdls    []Middleware
+}
+
+func (s *HTTPServer) RouteBuilder() *RouteBuilder {
+	return &RouteBuilder{server: s}
+}
+
+// Handle handleFunc 为 nil 的时候和 HTTPServer.Use 一样，只注册 middleware
+func (b *RouteBuilder) Handle(method string, path string, handleFunc HandleFunc, mdls ...Middleware) *RouteBuilder {
+	b.routes = append(b.routes, routeDef{method: method, path: path, handler: handleFunc, mdls: mdls})
+	return b
+}
+
+func (b *RouteBuilder) Get(path string, handleFunc HandleFunc, mdls ...Middleware) *RouteBuilder {
+	return b.Handle(http.MethodGet, path, handleFunc, mdls...)
+}
+
+func (b *RouteBuilder) Post(path string, handleFunc HandleFunc, mdls ...Middleware) *RouteBuilder {
+	return b.Handle(http.MethodPost, path, handleFunc, mdls...)
+}
+
+// Build 校验并注册所有的路由，出错的时候返回 RouteErrors
+// 校验是在一棵临时的路由树上进行的，它包含了 HTTPServer 已经注册的路由，
+// 所以和已有路由的冲突也会被检测出来
+func (b *RouteBuilder) Build() error {
+	scratch := newRouter()
+	for method, root := range b.server.trees {
+		root.walk(nil, func(n *node, _ []Middleware) {
+			if n.route != "" {
+				// 已有的路由树一定是合法的，重放不会出错
+				_ = scratch.tryAddRoute(method, n.route, n.handler, n.matchedMdls...)
+			}
+		})
+	}
+
+	var errs RouteErrors
+	for _, r := range b.routes {
+		if err := scratch.tryAddRoute(r.method, r.path, r.handler, r.mdls...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, r := range b.routes {
+		b.server.addRoute(r.method, r.path, r.handler, r.mdls...)
+	}
+	b.routes = nil
+	return nil
+}
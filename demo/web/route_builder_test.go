@@ -0,0 +1,92 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer_TryAddRoute(t *testing.T) {
+	s := NewHTTPServer()
+	mockHandler := func(ctx *Context) {}
+	require.NoError(t, s.TryAddRoute(http.MethodGet, "/user/:id", mockHandler))
+
+	testCases := []struct {
+		name string
+		path string
+
+		wantErr      string
+		wantExisting string
+	}{
+		{
+			name:    "invalid",
+			path:    "user",
+			wantErr: "GET user: web: 路径必须以 / 开头",
+		},
+		{
+			name:         "duplicate",
+			path:         "/user/:id",
+			wantErr:      "GET /user/:id 和已有路由 /user/:id 冲突: web: 路由冲突，重复注册[/user/:id]",
+			wantExisting: "/user/:id",
+		},
+		{
+			name:         "param",
+			path:         "/user/:name/detail",
+			wantErr:      "GET /user/:name/detail 和已有路由 /user/:id 冲突: web: 路由冲突，参数路由冲突，已有 :id，新注册 :name",
+			wantExisting: "/user/:id",
+		},
+		{
+			name:    "reg expr",
+			path:    "/order/:id(^[0-9+$)",
+			wantErr: "GET /order/:id(^[0-9+$): web: 正则表达式错误 error parsing regexp: missing closing ]: `[0-9+$`",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.TryAddRoute(http.MethodGet, tc.path, mockHandler)
+			assert.EqualError(t, err, tc.wantErr)
+			var routeErr *RouteError
+			require.True(t, errors.As(err, &routeErr))
+			assert.Equal(t, tc.wantExisting, routeErr.Existing)
+		})
+	}
+}
+
+func TestRouteBuilder_Build(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user/:id", func(ctx *Context) {})
+	handler := func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+
+	err := s.RouteBuilder().
+		Get("/order/:id", handler).
+		Get("/user/*", handler).
+		Get("/order/:oid", handler).
+		Post("/order", handler).
+		Post("/order", handler).
+		Build()
+	var errs RouteErrors
+	require.True(t, errors.As(err, &errs))
+	assert.EqualError(t, err, `web: 注册路由失败，共 3 个错误
+	GET /user/* 和已有路由 /user/:id 冲突: web: 非法路由，已有路径参数路由。不允许同时注册通配符路由和参数路由 [*]
+	GET /order/:oid 和已有路由 /order/:id 冲突: web: 路由冲突，参数路由冲突，已有 :id，新注册 :oid
+	POST /order 和已有路由 /order 冲突: web: 路由冲突，重复注册[/order]`)
+	// 一个都没注册
+	assert.Len(t, s.Routes(), 1)
+
+	require.NoError(t, s.RouteBuilder().
+		Get("/order/:id", handler).
+		Post("/order", handler).
+		Handle(http.MethodGet, "/order", nil, MaxBodySize(1024)).
+		Build())
+	assert.Len(t, s.Routes(), 3)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/order/12", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// RouteInfo 已经注册的路由
+type RouteInfo struct {
+	// Host 域名，默认域名为空，见 HTTPServer.Host
+	Host    string `json:"host,omitempty"`
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	// Handler 处理函数的名字，匿名函数会是 xxx.func1 这种形式
+	Handler string `json:"handler"`
+	// Middlewares 会作用在这个路由上的 middleware，
+	// 依次是 ServerWithMiddleware 注册的、域名上注册的，再是路径上通过 Use 注册的
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+// Routes 列出所有注册了 handler 的路由，先是默认域名，再按照域名排序，
+// 同一个域名下按照 HTTP 方法和路由排序
+// 注意通配符路由的 middleware 只统计了路径上的节点，
+// 比如 /a/* 上的 middleware 在 /a/b 命中的时候也会执行，但是这里不会体现
+func (s *HTTPServer) Routes() []RouteInfo {
+	global := funcNames(s.mdls)
+	routes := withMiddlewares(s.router.routes(), global)
+	for _, h := range s.sortedHosts() {
+		hostRoutes := withMiddlewares(h.routes(), append(global[:len(global):len(global)], funcNames(h.mdls)...))
+		for i := range hostRoutes {
+			hostRoutes[i].Host = h.pattern
+		}
+		routes = append(routes, hostRoutes...)
+	}
+	return routes
+}
+
+func withMiddlewares(routes []RouteInfo, mdls []string) []RouteInfo {
+	if len(mdls) == 0 {
+		return routes
+	}
+	for i := range routes {
+		routes[i].Middlewares = append(mdls[:len(mdls):len(mdls)], routes[i].Middlewares...)
+	}
+	return routes
+}
+
+func (s *HTTPServer) sortedHosts() []*HostRouter {
+	res := make([]*HostRouter, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		res = append(res, h)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].pattern < res[j].pattern
+	})
+	return res
+}
+
+// RoutesHandler 以 JSON 的形式输出所有路由，方便排查问题
+// 不会自动注册，需要的话自己注册，例如 server.Get("/debug/routes", server.RoutesHandler())
+// 最好只在内网或者 admin 端口上暴露
+func (s *HTTPServer) RoutesHandler() HandleFunc {
+	return func(ctx *Context) {
+		ctx.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := ctx.RespJSONOK(s.Routes()); err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte(err.Error())
+		}
+	}
+}
+
+// DumpTree 把路由树打印出来，用来排查路由冲突
+// 每个 HTTP 方法一棵树，子节点按照匹配的优先级排列：静态、正则、参数、通配符
+// 有域名路由的话，每个域名的路由树单独打印
+func (s *HTTPServer) DumpTree(w io.Writer) error {
+	if err := s.router.dumpTree(w); err != nil {
+		return err
+	}
+	for _, h := range s.sortedHosts() {
+		if _, err := fmt.Fprintf(w, "\nHost %s\n", h.pattern); err != nil {
+			return err
+		}
+		if err := h.dumpTree(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *router) methods() []string {
+	methods := make([]string, 0, len(r.trees))
+	for method := range r.trees {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (r *router) routes() []RouteInfo {
+	res := make([]RouteInfo, 0)
+	for _, method := range r.methods() {
+		r.trees[method].walk(nil, func(n *node, mdls []Middleware) {
+			if n.handler == nil {
+				return
+			}
+			res = append(res, RouteInfo{
+				Method:      method,
+				Pattern:     n.route,
+				Handler:     funcName(n.handler),
+				Middlewares: funcNames(mdls),
+			})
+		})
+	}
+	return res
+}
+
+// walk 深度优先遍历，mdls 是祖先节点上的 middleware
+func (n *node) walk(mdls []Middleware, fn func(n *node, mdls []Middleware)) {
+	mdls = append(mdls[:len(mdls):len(mdls)], n.matchedMdls...)
+	fn(n, mdls)
+	for _, child := range n.sortedChildren() {
+		child.walk(mdls, fn)
+	}
+}
+
+// sortedChildren 按照匹配的优先级返回子节点，静态节点按照字典序
+func (n *node) sortedChildren() []*node {
+	keys := make([]string, 0, len(n.children))
+	for key := range n.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make([]*node, 0, len(keys)+3)
+	for _, key := range keys {
+		res = append(res, n.children[key])
+	}
+	for _, child := range []*node{n.regChild, n.paramChild, n.starChild} {
+		if child != nil {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+func (r *router) dumpTree(w io.Writer) error {
+	var sb strings.Builder
+	for _, method := range r.methods() {
+		root := r.trees[method]
+		sb.WriteString(method)
+		sb.WriteByte(' ')
+		root.dump(&sb, "")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (n *node) dump(sb *strings.Builder, prefix string) {
+	sb.WriteString(n.path)
+	if n.handler != nil {
+		sb.WriteString(" -> ")
+		sb.WriteString(funcName(n.handler))
+	}
+	if len(n.matchedMdls) > 0 {
+		fmt.Fprintf(sb, " %v", funcNames(n.matchedMdls))
+	}
+	sb.WriteByte('\n')
+	children := n.sortedChildren()
+	for i, child := range children {
+		sb.WriteString(prefix)
+		if i == len(children)-1 {
+			sb.WriteString("└── ")
+			child.dump(sb, prefix+"    ")
+		} else {
+			sb.WriteString("├── ")
+			child.dump(sb, prefix+"│   ")
+		}
+	}
+}
+
+func funcName(fn any) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+func funcNames(mdls []Middleware) []string {
+	if len(mdls) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(mdls))
+	for _, mdl := range mdls {
+		res = append(res, funcName(mdl))
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func routeInfoHandler(ctx *Context) {}
+
+func routeInfoMdl(next HandleFunc) HandleFunc {
+	return next
+}
+
+func TestHTTPServer_Routes(t *testing.T) {
+	s := NewHTTPServer(ServerWithMiddleware(routeInfoMdl))
+	s.Get("/", routeInfoHandler)
+	s.Get("/user/:id", routeInfoHandler)
+	s.Get("/user/home", routeInfoHandler)
+	s.Post("/order/*", routeInfoHandler)
+	s.Use(http.MethodGet, "/user", MaxBodySize(1024))
+	s.Get("/debug/routes", s.RoutesHandler())
+
+	const (
+		handler = "bookstore/demo/web.routeInfoHandler"
+		global  = "bookstore/demo/web.routeInfoMdl"
+		maxBody = "bookstore/demo/web.MaxBodySize.func1"
+	)
+	wantRoutes := []RouteInfo{
+		{Method: http.MethodGet, Pattern: "/", Handler: handler, Middlewares: []string{global}},
+		{Method: http.MethodGet, Pattern: "/debug/routes", Handler: "bookstore/demo/web.(*HTTPServer).RoutesHandler.func1",
+			Middlewares: []string{global}},
+		{Method: http.MethodGet, Pattern: "/user/home", Handler: handler, Middlewares: []string{global, maxBody}},
+		{Method: http.MethodGet, Pattern: "/user/:id", Handler: handler, Middlewares: []string{global, maxBody}},
+		{Method: http.MethodPost, Pattern: "/order/*", Handler: handler, Middlewares: []string{global}},
+	}
+	assert.Equal(t, wantRoutes, s.Routes())
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var routes []RouteInfo
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &routes))
+	assert.Equal(t, wantRoutes, routes)
+
+	var sb strings.Builder
+	require.NoError(t, s.DumpTree(&sb))
+	assert.Equal(t, `GET / -> bookstore/demo/web.routeInfoHandler
+├── debug
+│   └── routes -> bookstore/demo/web.(*HTTPServer).RoutesHandler.func1
+└── user [bookstore/demo/web.MaxBodySize.func1]
+    ├── home -> bookstore/demo/web.routeInfoHandler
+    └── :id -> bookstore/demo/web.routeInfoHandler
+POST /
+└── order
+    └── * -> bookstore/demo/web.routeInfoHandler
+`, sb.String())
+}
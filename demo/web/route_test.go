@@ -236,6 +236,12 @@ func Test_router_AddRoute(t *testing.T) {
 		r.addRoute(http.MethodGet, "/a/b/c/:id", mockHandler)
 		r.addRoute(http.MethodGet, "/a/b/c/:name", mockHandler)
 	})
+
+	// 正则冲突
+	assert.PanicsWithValue(t, "web: 路由冲突，正则路由冲突，已有 :id(^[0-9]+$)，新注册 :id(^[a-z]+$)", func() {
+		r.addRoute(http.MethodGet, "/a/b/d/:id(^[0-9]+$)", mockHandler)
+		r.addRoute(http.MethodGet, "/a/b/d/:id(^[a-z]+$)", mockHandler)
+	})
 }
 
 // string 返回一个错误信息，帮助我们排查问题
@@ -697,3 +703,56 @@ func Test_findRoute_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func Test_findRoute_RegexMiddleware(t *testing.T) {
+	var mdlBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+
+	r := newRouter()
+	r.addRoute(http.MethodGet, "/order", nil, mdlBuilder('o'))
+	r.addRoute(http.MethodGet, "/order/new", nil, mdlBuilder('n'))
+	r.addRoute(http.MethodGet, "/order/:id(^[0-9]+$)", nil, mdlBuilder('r'))
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantResp string
+	}{
+		{
+			// 正则要用子节点自己的表达式匹配，父节点没有正则
+			name:     "regex match",
+			path:     "/order/123",
+			wantResp: "or",
+		},
+		{
+			name:     "regex not match",
+			path:     "/order/new",
+			wantResp: "on",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, ok := r.findRoute(http.MethodGet, tc.path)
+			if !assert.True(t, ok) {
+				return
+			}
+			mdls := mi.mdls
+			var root HandleFunc = func(ctx *Context) {
+				assert.Equal(t, tc.wantResp, string(ctx.RespData))
+			}
+			for i := len(mdls) - 1; i >= 0; i-- {
+				root = mdls[i](root)
+			}
+			root(&Context{
+				RespData: make([]byte, 0, len(tc.wantResp)),
+			})
+		})
+	}
+}
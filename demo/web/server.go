@@ -1,9 +1,15 @@
 package web
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 /*
@@ -26,6 +32,8 @@ type Server interface {
 	// addr 是监听地址，如果只指定端口，可以使用 ":8081"
 	// 或者 "localhost:8081"
 	Start(addr string) error
+	// Shutdown 优雅退出，等待正在处理的请求结束
+	Shutdown(ctx context.Context) error
 	// AddRoute 注册一个路由
 	// method 是 HTTP 方法
 	// path 是路径，必须以 / 为开头
@@ -45,6 +53,29 @@ type HTTPServer struct {
 	mdls []Middleware
 
 	tplEngine TemplateEngine
+
+	// trustedProxies 只有来自这些代理的请求，才会相信 X-Forwarded-For 之类的头部
+	trustedProxies []*net.IPNet
+
+	// maxBodySize 请求体的最大长度，0 表示不限制
+	maxBodySize int64
+	// JSON 解码的默认选项，BindJSON 使用
+	jsonUseNumber             bool
+	jsonDisallowUnknownFields bool
+
+	// 域名路由，见 Host
+	hosts         map[string]*HostRouter
+	hostParams    []*HostRouter
+	hostWildcards []*HostRouter
+
+	// admin 管理端口，见 ServerWithAdmin
+	admin *adminServer
+
+	// server Start 之后才有，Shutdown 的时候使用
+	mutex  sync.Mutex
+	server *http.Server
+	// closed 调用过 Shutdown 之后不能再 Start
+	closed bool
 }
 
 func NewHTTPServer(opts ...HTTPServerOption) *HTTPServer {
@@ -75,6 +106,47 @@ func ServerWithTemplateEngine(engine TemplateEngine) HTTPServerOption {
 	}
 }
 
+// ServerWithTrustedProxies 设置可信代理，可以是 CIDR，也可以是单个 IP
+// 只有直接连过来的是可信代理，Context.ClientIP 才会解析 Forwarded、X-Forwarded-For 和 X-Real-IP
+// 配置错误属于启动阶段的问题，所以直接 panic
+func ServerWithTrustedProxies(proxies ...string) HTTPServerOption {
+	return func(server *HTTPServer) {
+		for _, proxy := range proxies {
+			cidr := proxy
+			if !strings.Contains(cidr, "/") {
+				if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+					cidr += "/32"
+				} else {
+					cidr += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				panic(fmt.Sprintf("web: 非法的可信代理 %s", proxy))
+			}
+			server.trustedProxies = append(server.trustedProxies, ipNet)
+		}
+	}
+}
+
+// ServerWithMaxBodySize 限制请求体的最大长度，超过了 Context.Body 和 BindJSON 会返回 ErrBodyTooLarge
+// 单个路由可以通过 MaxBodySize 覆盖
+func ServerWithMaxBodySize(n int64) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.maxBodySize = n
+	}
+}
+
+// ServerWithJSONDecoder 设置 BindJSON 的默认解码选项
+// useNumber 为 true 的时候，数字会被解析为 json.Number 而不是 float64
+// disallowUnknownFields 为 true 的时候，JSON 里面出现结构体没有的字段会报错
+func ServerWithJSONDecoder(useNumber bool, disallowUnknownFields bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.jsonUseNumber = useNumber
+		server.jsonDisallowUnknownFields = disallowUnknownFields
+	}
+}
+
 // Use 会执行路由匹配，只有匹配上了的 mdls 才会生效
 // 这个只需要稍微改造一下路由树就可以实现
 func (s *HTTPServer) Use(method string, path string, mdls ...Middleware) {
@@ -90,6 +162,14 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Req:       r,
 		Resp:      w,
 		tplEngine: s.tplEngine,
+
+		trustedProxies: s.trustedProxies,
+
+		jsonUseNumber:             s.jsonUseNumber,
+		jsonDisallowUnknownFields: s.jsonDisallowUnknownFields,
+	}
+	if s.maxBodySize > 0 {
+		ctx.SetMaxBodySize(s.maxBodySize)
 	}
 
 	// 最后一个应该是 HTTPServer 执行路由匹配，执行用户代码
@@ -116,19 +196,32 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *HTTPServer) flashResp(ctx *Context) {
-	if ctx.RespStatusCode != 0 {
-		ctx.Resp.WriteHeader(ctx.RespStatusCode)
-	}
-	_, err := ctx.Resp.Write(ctx.RespData)
-	if err != nil {
+	if err := ctx.flushTo(ctx.Resp); err != nil {
 		log.Fatalln("回写响应失败", err)
 	}
 }
 
-// 查找路由，执行代码
+// 先匹配域名，再查找路由，执行代码
 func (s *HTTPServer) serve(ctx *Context) {
+	host, params := s.matchHost(ctx.Req.Host)
+	if host == nil {
+		s.serveRoute(&s.router, ctx)
+		return
+	}
+
+	ctx.HostParams = params
+	root := func(ctx *Context) {
+		s.serveRoute(&host.router, ctx)
+	}
+	for i := len(host.mdls) - 1; i >= 0; i-- {
+		root = host.mdls[i](root)
+	}
+	root(ctx)
+}
+
+func (s *HTTPServer) serveRoute(rt *router, ctx *Context) {
 	r := ctx.Req
-	info, found := s.findRoute(r.Method, r.URL.Path)
+	info, found := rt.findRoute(r.Method, r.URL.Path)
 	if !found || info.n == nil || info.n.handler == nil {
 		// 路由没有命中，就是404
 		ctx.RespStatusCode = http.StatusNotFound
@@ -138,7 +231,12 @@ func (s *HTTPServer) serve(ctx *Context) {
 
 	ctx.PathParams = info.pathParams
 	ctx.MatchedRoute = info.n.route
-	info.n.handler(ctx)
+	// 通过 Use 注册在路由上的 middleware
+	handler := info.n.handler
+	for i := len(info.mdls) - 1; i >= 0; i-- {
+		handler = info.mdls[i](handler)
+	}
+	handler(ctx)
 }
 
 // Start 启动服务器，用户指定端口
@@ -154,14 +252,131 @@ func (s *HTTPServer) Start(addr string) error {
 	// 在这里，可以让用户注册所谓的 after start 回调
 	// 比如说往你的 admin 注册一下自己这个实例
 	// 在这里执行一些你业务所需的前置条件
+	if s.admin != nil {
+		if err = s.admin.start(); err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+
+	srv := &http.Server{Handler: s}
+	s.mutex.Lock()
+	// Shutdown 可能发生在启动的过程中，这时候就不能再启动了
+	if s.closed {
+		s.mutex.Unlock()
+		_ = l.Close()
+		return http.ErrServerClosed
+	}
+	s.server = srv
+	// 已经在监听了，可以接流量了
+	// 在锁里面设置，Shutdown 之后就不会再变成 true
+	if s.admin != nil {
+		s.admin.ready.Store(true)
+	}
+	s.mutex.Unlock()
+	err = srv.Serve(l)
+	// Shutdown 导致的退出不算错误
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 优雅退出
+// 1. /readyz 变成 503，负载均衡不会再把新的请求发过来
+// 2. 等待 AdminWithDrainDelay 设置的时间
+// 3. 关闭业务端口，等待正在处理的请求结束，超时由 ctx 控制
+// 4. 关闭管理端口
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	srv := s.server
+	s.mutex.Unlock()
+
+	if s.admin != nil {
+		s.admin.ready.Store(false)
+		if s.admin.drainDelay > 0 {
+			select {
+			case <-time.After(s.admin.drainDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
 
-	return http.Serve(l, s)
+	var err error
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+	if s.admin != nil {
+		if adminErr := s.admin.shutdown(ctx); err == nil {
+			err = adminErr
+		}
+	}
+	return err
 }
 
 //func (s *HTTPServer) addRoute(method string, path string, handleFunc HandleFunc) {
 //	// 这里注册到路由树里面
 //}
 
+// TryAddRoute 注册路由，和 Get、Post 不同，非法或者冲突的路由返回 *RouteError 而不是 panic
+func (s *HTTPServer) TryAddRoute(method string, path string, handleFunc HandleFunc, mdls ...Middleware) error {
+	if err := s.tryAddRoute(method, path, handleFunc, mdls...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mountMethods Mount 会在这些方法上注册路由
+var mountMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// Mount 把 prefix 下面的所有请求都交给 handler 处理，例如 pprof、promhttp 或者一个 gin.Engine
+// 转发之前会去掉 prefix，例如挂载到 /debug 下面，/debug/pprof/ 到了 handler 那里就是 /pprof/
+// handler 直接写 Resp，所以不会经过 RespData，不过 RespStatusCode 依旧会被设置好，
+// access log 之类的 middleware 可以拿到状态码
+func (s *HTTPServer) Mount(prefix string, handler http.Handler) {
+	if prefix == "" || prefix[0] != '/' || (prefix != "/" && prefix[len(prefix)-1] == '/') {
+		panic(fmt.Sprintf("web: 非法的挂载路径 %s", prefix))
+	}
+	handleFunc := mountHandler(prefix, handler)
+	wildcard := prefix + "/*"
+	if prefix == "/" {
+		wildcard = "/*"
+	}
+	for _, method := range mountMethods {
+		s.addRoute(method, prefix, handleFunc)
+		s.addRoute(method, wildcard, handleFunc)
+	}
+}
+
+func mountHandler(prefix string, handler http.Handler) HandleFunc {
+	if prefix == "/" {
+		prefix = ""
+	}
+	return func(ctx *Context) {
+		req := ctx.Req.Clone(ctx.Req.Context())
+		req.URL.Path = stripPrefix(req.URL.Path, prefix)
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = stripPrefix(req.URL.RawPath, prefix)
+		}
+		w := &statusWriter{ResponseWriter: ctx.Resp}
+		handler.ServeHTTP(w, req)
+		ctx.RespStatusCode = w.statusCode()
+		ctx.respFlushed = true
+	}
+}
+
+func stripPrefix(path string, prefix string) string {
+	path = strings.TrimPrefix(path, prefix)
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
 func (s *HTTPServer) Get(path string, handleFunc HandleFunc) {
 	s.addRoute(http.MethodGet, path, handleFunc)
 }
@@ -3,7 +3,10 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestHTTPServer_ServeHTTP(t *testing.T) {
@@ -34,5 +37,78 @@ func TestHTTPServer_ServeHTTP(t *testing.T) {
 			}
 		},
 	}
-	server.ServeHTTP(nil, &http.Request{})
+	server.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+}
+
+func TestHTTPServer_Mount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path))
+	})
+
+	var status int
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			status = ctx.RespStatusCode
+		}
+	}))
+	s.Mount("/sub", mux)
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("user")
+	})
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "prefix",
+			method:   http.MethodGet,
+			path:     "/sub",
+			wantCode: http.StatusAccepted,
+			wantBody: "GET /",
+		},
+		{
+			name:     "sub path",
+			method:   http.MethodPost,
+			path:     "/sub/a/b",
+			wantCode: http.StatusAccepted,
+			wantBody: "POST /a/b",
+		},
+		{
+			name:     "not mounted",
+			method:   http.MethodGet,
+			path:     "/user",
+			wantCode: http.StatusOK,
+			wantBody: "user",
+		},
+		{
+			name:     "similar prefix",
+			method:   http.MethodGet,
+			path:     "/subway",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantCode, status)
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 非法的挂载路径 /sub/", func() {
+		s.Mount("/sub/", mux)
+	})
 }
@@ -3,7 +3,16 @@ package web
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 type TemplateEngine interface {
@@ -12,7 +21,7 @@ type TemplateEngine interface {
 	// data 渲染页面所需要的数据
 	Render(ctx context.Context, tplName string, data any) ([]byte, error)
 
-	// 渲染页面，数据写入到 Writer 里面
+	// 渲染页面，数据写入到 Writer 里面，见 StreamingTemplateEngine
 	// Render(ctx, "aa", map[]{}, responseWriter)
 	// Render(ctx context.Context, tplName string, data any, writer io.Writer) error
 	// 不需要，让具体实现自己去管自己的模板
@@ -20,19 +29,147 @@ type TemplateEngine interface {
 	// AddTemplate(tplName string, tpl []byte) error
 }
 
+// StreamingTemplateEngine 可选接口，渲染的结果直接写入 writer
+// 大页面不需要先完整地渲染到内存里面，Context.RenderStream 会优先使用它
+type StreamingTemplateEngine interface {
+	TemplateEngine
+	RenderTo(ctx context.Context, tplName string, data any, writer io.Writer) error
+}
+
+// RequestFuncMap 每次渲染都会重新生成的模板函数，可以拿到请求的 context
+// 例如 CSRF token 这种和请求相关的数据
+type RequestFuncMap func(ctx context.Context) template.FuncMap
+
+var _ StreamingTemplateEngine = &GoTemplateEngine{}
+
+type GoTemplateEngineOption func(e *GoTemplateEngine)
+
 type GoTemplateEngine struct {
 	T *template.Template
 	// 也可以考虑设计为 map[string]*template.Template
 	// 但是其实没太大必要，因为 template.Template 本身就提供了按名索引的功能
+
+	// 下面的字段是给 LoadPages 用的
+	// 每个页面有自己独立的模板集合：布局 + 公共片段 + 页面本身
+	// 这样不同页面里面同名的 block 就不会互相覆盖
+	fsys fs.FS
+	// layout 基础布局文件，页面通过 define 覆盖布局里面的 block
+	layout string
+	// partials 公共片段的 glob 模式
+	partials  []string
+	funcs     template.FuncMap
+	reqFuncs  []RequestFuncMap
+	hotReload bool
+
+	mutex sync.RWMutex
+	pages map[string]*pageTemplate
+}
+
+type pageTemplate struct {
+	t *template.Template
+	// entry 渲染的时候执行的模板，有布局的时候就是布局
+	entry string
+	// files 组成这个页面的文件和修改时间，热加载的时候用来判断有没有变化
+	files map[string]time.Time
+}
+
+func NewGoTemplateEngine(opts ...GoTemplateEngineOption) *GoTemplateEngine {
+	res := &GoTemplateEngine{
+		fsys:  os.DirFS("."),
+		funcs: template.FuncMap{},
+		pages: map[string]*pageTemplate{},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// TemplateWithFS 从 fsys 里面加载模板，例如 embed.FS。默认是当前目录
+func TemplateWithFS(fsys fs.FS) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.fsys = fsys
+	}
+}
+
+// TemplateWithLayout 指定基础布局文件
+func TemplateWithLayout(name string) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.layout = name
+	}
+}
+
+// TemplateWithPartials 指定公共片段，每个页面都可以使用
+func TemplateWithPartials(patterns ...string) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.partials = patterns
+	}
+}
+
+// TemplateWithFuncs 注册模板函数，同名的会覆盖内置的 urlFor
+func TemplateWithFuncs(funcs template.FuncMap) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		for name, fn := range funcs {
+			e.funcs[name] = fn
+		}
+	}
+}
+
+// TemplateWithRequestFuncs 注册和请求相关的模板函数，例如 CSRFFuncs 和 CSPFuncs
+// 注意每次渲染都要复制一遍模板，有一定的性能损耗
+func TemplateWithRequestFuncs(fns ...RequestFuncMap) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.reqFuncs = append(e.reqFuncs, fns...)
+	}
+}
+
+// TemplateWithHotReload 开发模式下使用，渲染之前检查文件有没有变化，变了就重新解析
+func TemplateWithHotReload(enabled bool) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.hotReload = enabled
+	}
 }
 
 func (e *GoTemplateEngine) Render(ctx context.Context,
 	tplName string, data any) ([]byte, error) {
 	bs := &bytes.Buffer{}
-	err := e.T.ExecuteTemplate(bs, tplName, data)
+	err := e.execute(ctx, bs, tplName, data)
 	return bs.Bytes(), err
 }
 
+func (e *GoTemplateEngine) execute(ctx context.Context, w io.Writer, tplName string, data any) error {
+	pt, err := e.page(tplName)
+	if err != nil {
+		return err
+	}
+	// 不是通过 LoadPages 加载的，使用 T
+	if pt == nil {
+		if e.T == nil {
+			return fmt.Errorf("web: 找不到模板 %s", tplName)
+		}
+		return e.T.ExecuteTemplate(w, tplName, data)
+	}
+
+	t := pt.t
+	if len(e.reqFuncs) > 0 {
+		// html/template 执行过之后就不能再 Clone 了
+		// 所以 pt.t 永远不执行，只作为原型
+		t, err = t.Clone()
+		if err != nil {
+			return err
+		}
+		for _, fn := range e.reqFuncs {
+			t.Funcs(fn(ctx))
+		}
+	}
+	return t.ExecuteTemplate(w, pt.entry, data)
+}
+
+func (e *GoTemplateEngine) RenderTo(ctx context.Context,
+	tplName string, data any, writer io.Writer) error {
+	return e.execute(ctx, writer, tplName, data)
+}
+
 // 以下这三个方法，是管理模板本身的方法。
 // Web 框架根本不在意你从哪里把模板搞到，它只关心 Render 方法要实现，所以说管
 // 理模板的方法并不算是 TemplateEngine 接口的一部分。
@@ -49,3 +186,259 @@ func (e *GoTemplateEngine) LoadFromFiles(filenames ...string) error {
 	e.T, err = template.ParseFiles(filenames...)
 	return err
 }
+
+// LoadFromFS 和 LoadFromGlob 一样，只是从 fsys 里面加载，模板名字是文件名
+func (e *GoTemplateEngine) LoadFromFS(fsys fs.FS, patterns ...string) error {
+	var err error
+	e.T, err = template.ParseFS(fsys, patterns...)
+	return err
+}
+
+// LoadPages 按页加载模板，模板名字是文件在 fsys 里面的路径，例如 users/index.gohtml
+// 每个页面都会和布局、公共片段组成一个独立的模板集合
+func (e *GoTemplateEngine) LoadPages(patterns ...string) error {
+	if e.fsys == nil {
+		e.fsys = os.DirFS(".")
+	}
+	names, err := e.glob(patterns)
+	if err != nil {
+		return err
+	}
+	pages := make(map[string]*pageTemplate, len(names))
+	for _, name := range names {
+		pt, err := e.parsePage(name)
+		if err != nil {
+			return err
+		}
+		pages[name] = pt
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.pages == nil {
+		e.pages = pages
+		return nil
+	}
+	for name, pt := range pages {
+		e.pages[name] = pt
+	}
+	return nil
+}
+
+// page 找到页面，没有按页加载过就返回 nil
+func (e *GoTemplateEngine) page(name string) (*pageTemplate, error) {
+	e.mutex.RLock()
+	pt, ok := e.pages[name]
+	e.mutex.RUnlock()
+	if !ok || !e.hotReload || !pt.changed(e.fsys) {
+		return pt, nil
+	}
+
+	// 布局或者公共片段也可能变了，所以整个页面重新解析
+	pt, err := e.parsePage(name)
+	if err != nil {
+		return nil, err
+	}
+	e.mutex.Lock()
+	e.pages[name] = pt
+	e.mutex.Unlock()
+	return pt, nil
+}
+
+func (e *GoTemplateEngine) parsePage(name string) (*pageTemplate, error) {
+	files := make([]string, 0, 8)
+	if e.layout != "" {
+		files = append(files, e.layout)
+	}
+	partials, err := e.glob(e.partials)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, partials...)
+
+	pt := &pageTemplate{
+		entry: name,
+		files: make(map[string]time.Time, len(files)+1),
+	}
+	if e.layout != "" {
+		pt.entry = e.layout
+	}
+	t := template.New(name).Funcs(e.funcMap())
+	for _, file := range files {
+		if file == name {
+			continue
+		}
+		content, err := e.readFile(pt, file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = t.New(file).Parse(content); err != nil {
+			return nil, err
+		}
+	}
+	// 页面本身放在最后解析，这样才能覆盖布局里面的 block
+	content, err := e.readFile(pt, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = t.Parse(content); err != nil {
+		return nil, err
+	}
+	pt.t = t
+	return pt, nil
+}
+
+func (e *GoTemplateEngine) readFile(pt *pageTemplate, name string) (string, error) {
+	fi, err := fs.Stat(e.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	content, err := fs.ReadFile(e.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	pt.files[name] = fi.ModTime()
+	return string(content), nil
+}
+
+func (e *GoTemplateEngine) glob(patterns []string) ([]string, error) {
+	res := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		names, err := fs.Glob(e.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, names...)
+	}
+	return res, nil
+}
+
+func (e *GoTemplateEngine) funcMap() template.FuncMap {
+	res := template.FuncMap{
+		"urlFor": URLFor,
+	}
+	// 解析的时候函数必须已经存在，所以先放一个占位的进去
+	for _, reqFn := range e.reqFuncs {
+		for name, fn := range reqFn(context.Background()) {
+			res[name] = fn
+		}
+	}
+	for name, fn := range e.funcs {
+		res[name] = fn
+	}
+	return res
+}
+
+// changed 判断组成页面的文件有没有变化
+// 只能发现已有文件的修改，新增的公共片段要等页面本身有变化才会被加载
+func (pt *pageTemplate) changed(fsys fs.FS) bool {
+	for name, modTime := range pt.files {
+		fi, err := fs.Stat(fsys, name)
+		if err != nil || !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// URLFor 根据路由生成 URL，多出来的参数会作为查询参数
+// 例如 URLFor("/user/:id", "id", 123, "tab", "posts") 得到 /user/123?tab=posts
+// 在模板里面是 {{urlFor "/user/:id" "id" .ID}}
+func URLFor(route string, pairs ...any) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", errors.New("web: URLFor 的参数必须是成对的")
+	}
+	params := make(map[string]string, len(pairs)/2)
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("web: URLFor 的参数名必须是字符串 %v", pairs[i])
+		}
+		params[key] = fmt.Sprint(pairs[i+1])
+		keys = append(keys, key)
+	}
+
+	segs := strings.Split(route, "/")
+	for i, seg := range segs {
+		if !strings.HasPrefix(seg, ":") {
+			continue
+		}
+		// :id 或者 :id(正则)
+		paramName, _, _ := strings.Cut(seg[1:], "(")
+		val, ok := params[paramName]
+		if !ok {
+			return "", fmt.Errorf("web: 缺少路径参数 %s", paramName)
+		}
+		segs[i] = url.PathEscape(val)
+		delete(params, paramName)
+	}
+
+	res := strings.Join(segs, "/")
+	if len(params) == 0 {
+		return res, nil
+	}
+	query := url.Values{}
+	// 按照传入的顺序添加，url.Values.Encode 会排序，结果是稳定的
+	for _, key := range keys {
+		if val, ok := params[key]; ok {
+			query.Set(key, val)
+		}
+	}
+	return res + "?" + query.Encode(), nil
+}
+
+// CSRFFieldName 表单里面 CSRF token 的字段名
+const CSRFFieldName = "csrf_token"
+
+type csrfTokenKey struct{}
+
+// ContextWithCSRFToken 把 CSRF token 放进 context，模板函数从这里读取
+func ContextWithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfTokenKey{}, token)
+}
+
+// CSRFTokenFromContext 读取 CSRF token，没有就返回空字符串
+func CSRFTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// CSRFFuncs 提供 csrfToken 和 csrfField 两个模板函数
+// 配合 TemplateWithRequestFuncs 使用
+func CSRFFuncs(ctx context.Context) template.FuncMap {
+	token := CSRFTokenFromContext(ctx)
+	return template.FuncMap{
+		"csrfToken": func() string {
+			return token
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+				CSRFFieldName, template.HTMLEscapeString(token)))
+		},
+	}
+}
+
+type cspNonceKey struct{}
+
+// ContextWithCSPNonce 把 CSP nonce 放进 context，模板函数从这里读取
+func ContextWithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, cspNonceKey{}, nonce)
+}
+
+// CSPNonceFromContext 读取 CSP nonce，没有就返回空字符串
+func CSPNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// CSPFuncs 提供 cspNonce 模板函数，例如 <script nonce="{{cspNonce}}">
+// 配合 TemplateWithRequestFuncs 使用
+func CSPFuncs(ctx context.Context) template.FuncMap {
+	nonce := CSPNonceFromContext(ctx)
+	return template.FuncMap{
+		"cspNonce": func() string {
+			return nonce
+		},
+	}
+}
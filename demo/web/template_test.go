@@ -0,0 +1,258 @@
+package web
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplateEngine_LoadPages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.gohtml": {Data: []byte(
+			`<title>{{block "title" .}}默认标题{{end}}</title>{{template "partials/nav.gohtml" .}}{{block "content" .}}{{end}}`)},
+		"partials/nav.gohtml": {Data: []byte(`<nav>{{upper "nav"}}</nav>`)},
+		"users/index.gohtml": {Data: []byte(
+			`{{define "title"}}用户{{end}}{{define "content"}}<a href="{{urlFor "/user/:id" "id" .ID}}">{{.Name}}</a>{{end}}`)},
+		"posts/index.gohtml": {Data: []byte(`{{define "content"}}<p>{{.}}</p>{{end}}`)},
+	}
+	engine := NewGoTemplateEngine(
+		TemplateWithFS(fsys),
+		TemplateWithLayout("layout.gohtml"),
+		TemplateWithPartials("partials/*.gohtml"),
+		TemplateWithFuncs(template.FuncMap{"upper": strings.ToUpper}),
+	)
+	require.NoError(t, engine.LoadPages("users/*.gohtml", "posts/*.gohtml"))
+
+	testCases := []struct {
+		name    string
+		tplName string
+		data    any
+
+		wantPage string
+		wantErr  string
+	}{
+		{
+			name:    "users",
+			tplName: "users/index.gohtml",
+			data: struct {
+				ID   int
+				Name string
+			}{ID: 12, Name: "Tom"},
+			wantPage: `<title>用户</title><nav>NAV</nav><a href="/user/12">Tom</a>`,
+		},
+		{
+			// 同名的 content block 不会和 users 冲突
+			name:     "posts",
+			tplName:  "posts/index.gohtml",
+			data:     "<script>",
+			wantPage: `<title>默认标题</title><nav>NAV</nav><p>&lt;script&gt;</p>`,
+		},
+		{
+			name:    "not found",
+			tplName: "orders/index.gohtml",
+			wantErr: "web: 找不到模板 orders/index.gohtml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := engine.Render(context.Background(), tc.tplName, tc.data)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantPage, string(page))
+		})
+	}
+}
+
+func TestGoTemplateEngine_RequestFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"login.gohtml": {Data: []byte(`<form>{{csrfField}}</form>{{csrfToken}}`)},
+	}
+	engine := NewGoTemplateEngine(TemplateWithFS(fsys), TemplateWithRequestFuncs(CSRFFuncs))
+	require.NoError(t, engine.LoadPages("*.gohtml"))
+
+	for _, token := range []string{"token-1", "token-2"} {
+		ctx := ContextWithCSRFToken(context.Background(), token)
+		page, err := engine.Render(ctx, "login.gohtml", nil)
+		require.NoError(t, err)
+		assert.Equal(t, `<form><input type="hidden" name="csrf_token" value="`+token+`"></form>`+token, string(page))
+	}
+}
+
+func TestGoTemplateEngine_HotReload(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.gohtml": {Data: []byte(`[{{block "content" .}}{{end}}]`), ModTime: time.Unix(100, 0)},
+		"index.gohtml":  {Data: []byte(`{{define "content"}}v1{{end}}`), ModTime: time.Unix(100, 0)},
+	}
+	for _, hotReload := range []bool{true, false} {
+		engine := NewGoTemplateEngine(TemplateWithFS(fsys),
+			TemplateWithLayout("layout.gohtml"), TemplateWithHotReload(hotReload))
+		require.NoError(t, engine.LoadPages("index.gohtml"))
+		page, err := engine.Render(context.Background(), "index.gohtml", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "[v1]", string(page))
+
+		fsys["layout.gohtml"] = &fstest.MapFile{Data: []byte(`({{block "content" .}}{{end}})`), ModTime: time.Unix(200, 0)}
+		page, err = engine.Render(context.Background(), "index.gohtml", nil)
+		require.NoError(t, err)
+		if hotReload {
+			assert.Equal(t, "(v1)", string(page))
+		} else {
+			assert.Equal(t, "[v1]", string(page))
+		}
+		fsys["layout.gohtml"] = &fstest.MapFile{Data: []byte(`[{{block "content" .}}{{end}}]`), ModTime: time.Unix(100, 0)}
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	testCases := []struct {
+		name  string
+		route string
+		pairs []any
+
+		wantURL string
+		wantErr string
+	}{
+		{
+			name:    "static",
+			route:   "/user/home",
+			wantURL: "/user/home",
+		},
+		{
+			name:    "param and reg",
+			route:   "/user/:id/post/:pid(^[0-9]+$)",
+			pairs:   []any{"id", 12, "pid", int64(34)},
+			wantURL: "/user/12/post/34",
+		},
+		{
+			name:    "escape and query",
+			route:   "/search/:kw",
+			pairs:   []any{"kw", "a/b", "page", 2, "size", 10},
+			wantURL: "/search/a%2Fb?page=2&size=10",
+		},
+		{
+			name:    "missing param",
+			route:   "/user/:id",
+			wantErr: "web: 缺少路径参数 id",
+		},
+		{
+			name:    "odd pairs",
+			route:   "/user/:id",
+			pairs:   []any{"id"},
+			wantErr: "web: URLFor 的参数必须是成对的",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := URLFor(tc.route, tc.pairs...)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantURL, res)
+		})
+	}
+}
+
+func TestContext_RenderStream(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.gohtml":    {Data: []byte(`<p>{{.}}</p>`)},
+		"error.gohtml":   {Data: []byte(`{{.Name}}`)},
+		"partial.gohtml": {Data: []byte(`<p>{{.Name}}</p>`)},
+	}
+	engine := NewGoTemplateEngine(TemplateWithFS(fsys))
+	require.NoError(t, engine.LoadPages("*.gohtml"))
+
+	testCases := []struct {
+		name    string
+		engine  TemplateEngine
+		tplName string
+		status  int
+
+		wantCode     int
+		wantBody     string
+		wantErr      bool
+		wantRespData bool
+	}{
+		{
+			name:     "stream",
+			engine:   engine,
+			tplName:  "page.gohtml",
+			wantCode: http.StatusOK,
+			wantBody: "<p>hello</p>",
+		},
+		{
+			name:     "stream with status",
+			engine:   engine,
+			tplName:  "page.gohtml",
+			status:   http.StatusNotFound,
+			wantCode: http.StatusNotFound,
+			wantBody: "<p>hello</p>",
+		},
+		{
+			// 什么都没写出去，还能返回 500
+			name:     "stream error",
+			engine:   engine,
+			tplName:  "error.gohtml",
+			wantCode: http.StatusInternalServerError,
+			wantErr:  true,
+		},
+		{
+			// 已经写出去了一部分，状态码没法改了
+			name:     "stream partial error",
+			engine:   engine,
+			tplName:  "partial.gohtml",
+			wantCode: http.StatusOK,
+			wantBody: "<p>",
+			wantErr:  true,
+		},
+		{
+			name:         "fallback to buffer",
+			engine:       bufferOnlyEngine{engine: engine},
+			tplName:      "page.gohtml",
+			wantCode:     http.StatusOK,
+			wantBody:     "<p>hello</p>",
+			wantRespData: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(ServerWithTemplateEngine(tc.engine))
+			s.Get("/page", func(ctx *Context) {
+				ctx.RespStatusCode = tc.status
+				err := ctx.RenderStream(tc.tplName, "hello")
+				assert.Equal(t, tc.wantErr, err != nil)
+				assert.Equal(t, tc.wantRespData, len(ctx.RespData) > 0)
+			})
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/page", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+// bufferOnlyEngine 只实现了 TemplateEngine
+type bufferOnlyEngine struct {
+	engine TemplateEngine
+}
+
+func (b bufferOnlyEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	return b.engine.Render(ctx, tplName, data)
+}
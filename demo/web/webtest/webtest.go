@@ -0,0 +1,263 @@
+// Package webtest 在进程内测试 web.HTTPServer，不需要真的监听端口
+//
+//	client := webtest.New(t, server)
+//	var user User
+//	client.Get("/user/12").WithHeader("Authorization", "Bearer xxx").Expect(http.StatusOK).JSONBody(&user)
+package webtest
+
+import (
+	"bookstore/demo/web"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv 设置了这个环境变量的时候，ExpectGolden 会用实际的响应覆盖 golden 文件
+// 例如 WEBTEST_UPDATE=1 go test ./...
+const UpdateGoldenEnv = "WEBTEST_UPDATE"
+
+// Client 直接调用 handler.ServeHTTP
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+	header  http.Header
+}
+
+// New handler 一般是 *web.HTTPServer，也可以是任意的 http.Handler
+func New(t testing.TB, handler http.Handler) *Client {
+	return &Client{t: t, handler: handler, header: http.Header{}}
+}
+
+// WithHeader 之后所有的请求都会带上这个头部，例如登录之后的 Authorization
+func (c *Client) WithHeader(key string, val string) *Client {
+	c.header.Set(key, val)
+	return c
+}
+
+func (c *Client) Get(path string) *Request {
+	return c.NewRequest(http.MethodGet, path)
+}
+
+func (c *Client) Post(path string) *Request {
+	return c.NewRequest(http.MethodPost, path)
+}
+
+func (c *Client) Put(path string) *Request {
+	return c.NewRequest(http.MethodPut, path)
+}
+
+func (c *Client) Delete(path string) *Request {
+	return c.NewRequest(http.MethodDelete, path)
+}
+
+func (c *Client) NewRequest(method string, path string) *Request {
+	req := httptest.NewRequest(method, path, nil)
+	for key, vals := range c.header {
+		req.Header[key] = append([]string(nil), vals...)
+	}
+	return &Request{t: c.t, client: c, req: req}
+}
+
+// Request 链式构造请求，Expect 或者 Do 的时候才真正发出去
+type Request struct {
+	t      testing.TB
+	client *Client
+	req    *http.Request
+}
+
+func (r *Request) WithHeader(key string, val string) *Request {
+	r.req.Header.Set(key, val)
+	return r
+}
+
+func (r *Request) WithCookie(cookie *http.Cookie) *Request {
+	r.req.AddCookie(cookie)
+	return r
+}
+
+// WithHost 设置 Host，测试 HTTPServer.Host 注册的路由
+func (r *Request) WithHost(host string) *Request {
+	r.req.Host = host
+	return r
+}
+
+func (r *Request) WithQuery(key string, val string) *Request {
+	query := r.req.URL.Query()
+	query.Add(key, val)
+	r.req.URL.RawQuery = query.Encode()
+	return r
+}
+
+func (r *Request) WithBody(contentType string, body []byte) *Request {
+	r.req.Body = io.NopCloser(bytes.NewReader(body))
+	r.req.ContentLength = int64(len(body))
+	if contentType != "" {
+		r.req.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// WithJSON 把 val 序列化成 JSON 作为请求体
+func (r *Request) WithJSON(val any) *Request {
+	data, err := json.Marshal(val)
+	require.NoError(r.t, err)
+	return r.WithBody("application/json", data)
+}
+
+func (r *Request) WithForm(form url.Values) *Request {
+	return r.WithBody("application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// Do 发送请求
+func (r *Request) Do() *Response {
+	recorder := httptest.NewRecorder()
+	r.client.handler.ServeHTTP(recorder, r.req)
+	return &Response{t: r.t, Recorder: recorder}
+}
+
+// Expect 发送请求，并且断言状态码
+func (r *Request) Expect(statusCode int) *Response {
+	resp := r.Do()
+	return resp.ExpectStatus(statusCode)
+}
+
+// Response 响应，断言失败会调用 t.Errorf，不会中断测试，
+// 只有 JSONBody 解析失败会中断，因为后面的断言已经没有意义了
+type Response struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+}
+
+func (r *Response) StatusCode() int {
+	return r.Recorder.Code
+}
+
+func (r *Response) Header() http.Header {
+	return r.Recorder.Header()
+}
+
+func (r *Response) Body() string {
+	return r.Recorder.Body.String()
+}
+
+func (r *Response) Cookies() []*http.Cookie {
+	return r.Recorder.Result().Cookies()
+}
+
+func (r *Response) ExpectStatus(statusCode int) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, statusCode, r.Recorder.Code, "响应：%s", r.Body())
+	return r
+}
+
+func (r *Response) ExpectHeader(key string, val string) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, val, r.Recorder.Header().Get(key), "响应头 %s", key)
+	return r
+}
+
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, body, r.Body())
+	return r
+}
+
+// ExpectJSON 忽略格式和字段顺序，比较 JSON 是否相等
+func (r *Response) ExpectJSON(expected string) *Response {
+	r.t.Helper()
+	assert.JSONEq(r.t, expected, r.Body())
+	return r
+}
+
+// JSONBody 把响应解析到 val 里面
+func (r *Response) JSONBody(val any) *Response {
+	r.t.Helper()
+	require.NoError(r.t, json.Unmarshal(r.Recorder.Body.Bytes(), val), "响应：%s", r.Body())
+	return r
+}
+
+// ExpectGolden 和 testdata/<name>.golden 比较
+// JSON 响应会被格式化之后再比较和保存，方便 review golden 文件的变更
+func (r *Response) ExpectGolden(name string) *Response {
+	r.t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	actual := normalize(r.Recorder.Body.Bytes())
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(r.t, os.WriteFile(path, actual, 0o644))
+		return r
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(r.t, err, "golden 文件不存在，可以设置 %s=1 生成", UpdateGoldenEnv)
+	assert.Equal(r.t, string(expected), string(actual), "和 %s 不一致，确认无误之后可以设置 %s=1 更新", path, UpdateGoldenEnv)
+	return r
+}
+
+func normalize(body []byte) []byte {
+	if !json.Valid(body) {
+		return body
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return body
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+type ContextOption func(ctx *web.Context)
+
+// WithPathParams 模拟路由匹配的结果
+func WithPathParams(params map[string]string) ContextOption {
+	return func(ctx *web.Context) {
+		ctx.PathParams = params
+	}
+}
+
+func WithMatchedRoute(route string) ContextOption {
+	return func(ctx *web.Context) {
+		ctx.MatchedRoute = route
+	}
+}
+
+// NewContext 构造一个 Context，用来单独测试某个 HandleFunc 或者 Middleware，不需要经过路由
+// handler 执行完之后，缓存的响应在 ctx.RespData 和 ctx.RespStatusCode 里面，
+// 直接写到 Resp 里面的部分（例如头部）在返回的 recorder 里面
+func NewContext(req *http.Request, opts ...ContextOption) (*web.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx := &web.Context{Req: req, Resp: recorder}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+	return ctx, recorder
+}
+
+// NewRequest 是 httptest.NewRequest 的简写，body 为空字符串的时候没有请求体
+func NewRequest(method string, target string, body string) *http.Request {
+	if body == "" {
+		return httptest.NewRequest(method, target, nil)
+	}
+	return httptest.NewRequest(method, target, strings.NewReader(body))
+}
+
+// RunMiddleware 执行 mdl，next 是被它包住的 handler，返回 next 有没有被调用
+func RunMiddleware(mdl web.Middleware, ctx *web.Context, next web.HandleFunc) bool {
+	called := false
+	mdl(func(ctx *web.Context) {
+		called = true
+		if next != nil {
+			next(ctx)
+		}
+	})(ctx)
+	return called
+}
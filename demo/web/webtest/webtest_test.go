@@ -0,0 +1,103 @@
+package webtest
+
+import (
+	"bookstore/demo/web"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func newServer() *web.HTTPServer {
+	s := web.NewHTTPServer()
+	s.Get("/user/:id", func(ctx *web.Context) {
+		if ctx.Req.Header.Get("Authorization") == "" {
+			ctx.RespStatusCode = http.StatusUnauthorized
+			return
+		}
+		id, err := ctx.PathValueV2("id").ToInt64()
+		if err != nil {
+			ctx.RespStatusCode = http.StatusBadRequest
+			return
+		}
+		ctx.Resp.Header().Set("Content-Type", "application/json")
+		_ = ctx.RespJSONOK(user{ID: id, Name: queryName(ctx)})
+	})
+	s.Post("/user", func(ctx *web.Context) {
+		var u user
+		if err := ctx.BindJSON(&u); err != nil {
+			return
+		}
+		_ = ctx.RespJSON(http.StatusCreated, u)
+	})
+	s.Post("/login", func(ctx *web.Context) {
+		name, _ := ctx.FormValue("name")
+		ctx.SetCookie(&http.Cookie{Name: "session", Value: name})
+		ctx.RespStatusCode = http.StatusOK
+	})
+	return s
+}
+
+func TestClient(t *testing.T) {
+	client := New(t, newServer())
+
+	client.Get("/user/12").Expect(http.StatusUnauthorized)
+
+	client.WithHeader("Authorization", "Bearer token")
+	var u user
+	client.Get("/user/12").WithQuery("name", "Jerry").
+		Expect(http.StatusOK).
+		ExpectHeader("Content-Type", "application/json").
+		JSONBody(&u)
+	assert.Equal(t, user{ID: 12, Name: "Jerry"}, u)
+
+	client.Get("/user/abc").Expect(http.StatusBadRequest)
+
+	client.Post("/user").WithJSON(user{ID: 1, Name: "Tom"}).
+		Expect(http.StatusCreated).
+		ExpectJSON(`{"name":"Tom","id":1}`).
+		ExpectGolden("create_user")
+
+	cookies := client.Post("/login").WithForm(url.Values{"name": {"Tom"}}).
+		Expect(http.StatusOK).Cookies()
+	assert.Equal(t, "Tom", cookies[0].Value)
+}
+
+func TestNewContext(t *testing.T) {
+	ctx, recorder := NewContext(NewRequest(http.MethodGet, "/user/12", ""),
+		WithPathParams(map[string]string{"id": "12"}))
+	ctx.Req.Header.Set("Authorization", "Bearer token")
+	handler := func(ctx *web.Context) {
+		id, _ := ctx.PathValue("id")
+		ctx.Resp.Header().Set("X-User", id)
+		ctx.RespStatusCode = http.StatusOK
+	}
+	handler(ctx)
+	assert.Equal(t, http.StatusOK, ctx.RespStatusCode)
+	assert.Equal(t, "12", recorder.Header().Get("X-User"))
+
+	deny := func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if ctx.Req.Header.Get("Authorization") == "" {
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			next(ctx)
+		}
+	}
+	assert.True(t, RunMiddleware(deny, ctx, handler))
+	ctx, _ = NewContext(NewRequest(http.MethodGet, "/user/12", ""))
+	assert.False(t, RunMiddleware(deny, ctx, handler))
+	assert.Equal(t, http.StatusUnauthorized, ctx.RespStatusCode)
+}
+
+func queryName(ctx *web.Context) string {
+	name, _ := ctx.QueryValueV2("name").Default("Tom").String()
+	return name
+}
@@ -3,8 +3,16 @@ package code
 import "errors"
 
 var (
-	ErrorUserExist    = errors.New("username already exist")
-	ErrorUserNotExist = errors.New("username already exist")
-	ErrorUserNotLogin = errors.New("user is not login")
-	ErrorInvalidID    = errors.New("id is not valid")
+	ErrorUserExist       = errors.New("username already exist")
+	ErrorUserNotExist    = errors.New("username already exist")
+	ErrorUserNotLogin    = errors.New("user is not login")
+	ErrorInvalidID       = errors.New("id is not valid")
+	ErrorInvalidPassword = errors.New("password is not correct")
+	ErrorTokenRevoked    = errors.New("token has been revoked")
+
+	ErrorPostNotExist   = errors.New("post not exist")
+	ErrorVoteTimeExpire = errors.New("vote time expire")
+	ErrorVoteRepeated   = errors.New("vote repeated")
+
+	ErrorCommunityExist = errors.New("community name already exist")
 )
@@ -0,0 +1,110 @@
+package comment
+
+import (
+	"bookstore/web_app/dao/mysql"
+	"bookstore/web_app/pkg/cursor"
+	"bookstore/web_app/snowflake"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var db = mysql.GetDBConn()
+
+// GenAndInsertComment 生成评论 id 并保存评论
+func GenAndInsertComment(c DBComment) (int64, error) {
+	c.ID = snowflake.GenID()
+
+	sqlStr := `insert into comment(comment_id, post_id, author_id, parent_id, root_id, content, status)
+    values (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := db.Exec(sqlStr, c.ID, c.PostID, c.AuthorID, c.ParentID, c.RootID, c.Content, StatusNormal)
+	return c.ID, err
+}
+
+// GetCommentByID 根据评论 id 查询评论，包括已经删除的评论
+func GetCommentByID(cid int64) (DBComment, error) {
+	sqlStr := `select comment_id, post_id, author_id, parent_id, root_id, content, status, create_time
+    from comment where comment_id = ?`
+
+	var c DBComment
+	err := db.Get(&c, sqlStr, cid)
+	return c, err
+}
+
+// GetCommentListByCursor 按照评论 id 正序查询一个楼层里的评论
+// rootID 为 0 的时候查询帖子下面每个楼层的第一条评论
+// 已经删除的评论也会返回，保证楼层结构完整
+func GetCommentListByCursor(postID, rootID int64, c cursor.Cursor, size int64) ([]DBComment, error) {
+	sqlStr := `select comment_id, post_id, author_id, parent_id, root_id, content, status, create_time
+    from comment
+    where post_id = ? and root_id = ? and comment_id > ?
+    order by comment_id
+    limit ?`
+
+	res := make([]DBComment, 0, size)
+	err := db.Select(&res, sqlStr, postID, rootID, c.ID, size)
+	return res, err
+}
+
+// DeleteComment 软删除评论
+func DeleteComment(cid int64) error {
+	sqlStr := `update comment set status = ? where comment_id = ?`
+
+	_, err := db.Exec(sqlStr, StatusDeleted, cid)
+	return err
+}
+
+// GetReplyCounts 批量查询帖子 postID 的楼层里没有删除的回复数，返回 root_id 到回复数的映射
+// 带上 post_id 才能用到 post_id_root_id_idx
+func GetReplyCounts(postID int64, rootIDs []int64) (map[int64]int64, error) {
+	res := make(map[int64]int64, len(rootIDs))
+	if len(rootIDs) == 0 {
+		return res, nil
+	}
+	sqlStr := `select root_id, count(1) as cnt from comment
+    where post_id = ? and root_id in (?) and status = ?
+    group by root_id`
+	query, args, err := sqlx.In(sqlStr, postID, rootIDs, StatusNormal)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		RootID int64 `db:"root_id"`
+		Count  int64 `db:"cnt"`
+	}
+	if err = db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		res[row.RootID] = row.Count
+	}
+	return res, nil
+}
+
+// CountByPostIDs 批量查询帖子没有删除的评论数，返回 post_id 到评论数的映射
+func CountByPostIDs(ids []int64) (map[int64]int64, error) {
+	res := make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	sqlStr := `select post_id, count(1) as cnt from comment
+    where post_id in (?) and status = ?
+    group by post_id`
+	query, args, err := sqlx.In(sqlStr, ids, StatusNormal)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		PostID int64 `db:"post_id"`
+		Count  int64 `db:"cnt"`
+	}
+	if err = db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		res[row.PostID] = row.Count
+	}
+	return res, nil
+}
@@ -0,0 +1,40 @@
+package comment
+
+import "time"
+
+// 评论状态
+const (
+	StatusDeleted int32 = iota
+	StatusNormal
+)
+
+// deletedContent 已经删除的评论展示的内容
+const deletedContent = "该评论已删除"
+
+type DBComment struct {
+	ID       int64 `json:"id" db:"comment_id"`
+	PostID   int64 `json:"post_id" db:"post_id"`
+	AuthorID int64 `json:"author_id" db:"author_id"`
+	// ParentID 回复的评论，0 表示直接评论帖子
+	ParentID int64 `json:"parent_id" db:"parent_id"`
+	// RootID 所在楼层的第一条评论，0 表示自己就是第一条
+	RootID     int64     `json:"root_id" db:"root_id"`
+	Content    string    `json:"content" db:"content"`
+	Status     int32     `json:"status" db:"status"`
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
+
+// ApiComment 评论列表接口的结构体
+type ApiComment struct {
+	DBComment
+
+	AuthorName string `json:"author_name"`
+	// ReplyCount 楼层里的回复数，只有楼层的第一条评论有
+	ReplyCount int64 `json:"reply_count"`
+}
+
+// commentReq 发表评论的请求参数
+type commentReq struct {
+	Content  string `json:"content" binding:"required,max=2048"`
+	ParentID int64  `json:"parent_id"`
+}
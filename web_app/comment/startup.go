@@ -0,0 +1,263 @@
+package comment
+
+import (
+	"bookstore/web_app/community"
+	"bookstore/web_app/controller"
+	"bookstore/web_app/loader"
+	"bookstore/web_app/logger"
+	"bookstore/web_app/pkg/cursor"
+	"bookstore/web_app/post"
+	"bookstore/web_app/user"
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxPageSize 每一页最多的评论数
+const maxPageSize = 100
+
+func init() {
+	// 帖子详情和列表里的评论数
+	post.SetCommentCounter(CountByPostIDs)
+}
+
+// getPublishedPost 查询路径里的帖子，只有已发布的帖子可以评论和查看评论
+// 返回 false 的时候已经写好了响应
+func getPublishedPost(ctx *gin.Context) (post.DBPost, bool) {
+	pid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return post.DBPost{}, false
+	}
+
+	p, err := post.GetPostByID(pid)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && p.Status != post.StatusPublished) {
+		controller.ResponseError(ctx, controller.CodePostNotExist)
+		return post.DBPost{}, false
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("GetPostByID failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return post.DBPost{}, false
+	}
+	return p, true
+}
+
+// CreateCommentHandler 发表评论，parent_id 不为 0 的时候是回复别的评论
+// POST /api/v1/post/:id/comments
+func CreateCommentHandler(ctx *gin.Context) {
+	req := commentReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.Ctx(ctx).Error("create comment with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+
+	p, ok := getPublishedPost(ctx)
+	if !ok {
+		return
+	}
+
+	c := DBComment{
+		PostID:   p.ID,
+		AuthorID: userID,
+		Content:  req.Content,
+	}
+	if req.ParentID != 0 {
+		parent, err := GetCommentByID(req.ParentID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && (parent.PostID != p.ID || parent.Status != StatusNormal)) {
+			controller.ResponseError(ctx, controller.CodeCommentNotExist)
+			return
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error("GetCommentByID failed",
+				zap.Int64("parent_id", req.ParentID),
+				zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			return
+		}
+		// 回复都挂在楼层的第一条评论下面
+		c.ParentID = parent.ID
+		c.RootID = parent.RootID
+		if c.RootID == 0 {
+			c.RootID = parent.ID
+		}
+	}
+
+	cid, err := GenAndInsertComment(c)
+	if err != nil {
+		logger.Ctx(ctx).Error("GenAndInsertComment failed", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, gin.H{"id": cid})
+}
+
+// GetCommentListHandler 按照楼层分页查询评论
+// 不传 root_id 的时候查询帖子下面每个楼层的第一条评论，传了的时候查询这个楼层里的回复
+// GET /api/v1/post/:id/comments?root_id=&cursor=&size=
+func GetCommentListHandler(ctx *gin.Context) {
+	p, ok := getPublishedPost(ctx)
+	if !ok {
+		return
+	}
+
+	rootID, err := strconv.ParseInt(ctx.DefaultQuery("root_id", "0"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	size, err := strconv.ParseInt(ctx.DefaultQuery("size", "10"), 10, 64)
+	if err != nil || size < 1 || size > maxPageSize {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	c, err := cursor.Decode(ctx.Query("cursor"))
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	data, err := getCommentList(ctx.Request.Context(), p.ID, rootID, c, size)
+	if err != nil {
+		logger.Ctx(ctx).Error("getCommentList failed",
+			zap.Int64("pid", p.ID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, data)
+}
+
+func getCommentList(ctx context.Context, postID, rootID int64, c cursor.Cursor, size int64) (cursor.Page, error) {
+	// 多查一条用来判断是否还有下一页
+	comments, err := GetCommentListByCursor(postID, rootID, c, size+1)
+	if err != nil {
+		return cursor.Page{}, err
+	}
+	hasMore := int64(len(comments)) > size
+	if hasMore {
+		comments = comments[:size]
+	}
+
+	authorIDs := make([]int64, 0, len(comments))
+	for _, cm := range comments {
+		authorIDs = append(authorIDs, cm.AuthorID)
+	}
+	users, err := loader.Users(ctx, authorIDs)
+	if err != nil {
+		return cursor.Page{}, err
+	}
+
+	// 只有楼层的第一条评论需要回复数
+	var replyCounts map[int64]int64
+	if rootID == 0 {
+		ids := make([]int64, 0, len(comments))
+		for _, cm := range comments {
+			ids = append(ids, cm.ID)
+		}
+		if replyCounts, err = GetReplyCounts(postID, ids); err != nil {
+			return cursor.Page{}, err
+		}
+	}
+
+	list := make([]ApiComment, 0, len(comments))
+	for _, cm := range comments {
+		item := ApiComment{
+			DBComment:  cm,
+			ReplyCount: replyCounts[cm.ID],
+		}
+		if cm.Status == StatusDeleted {
+			// 删除的评论保留楼层结构，但是不展示内容和作者
+			item.Content = deletedContent
+			item.AuthorID = 0
+		} else if u, ok := users[cm.AuthorID]; ok {
+			item.AuthorName = u.Username
+		}
+		list = append(list, item)
+	}
+
+	page := cursor.Page{List: list, HasMore: hasMore}
+	if hasMore {
+		page.NextCursor = cursor.Cursor{ID: comments[len(comments)-1].ID}.Encode()
+	}
+	return page, nil
+}
+
+// canDeleteComment 评论的作者、帖子的作者和社区的版主可以删除评论
+func canDeleteComment(userID int64, c DBComment, p post.DBPost) (bool, error) {
+	if c.AuthorID == userID || p.AuthorID == userID {
+		return true, nil
+	}
+	return community.IsModerator(p.CommunityID, userID)
+}
+
+// DeleteCommentHandler 删除评论，楼层里的回复会保留
+// DELETE /api/v1/post/:id/comments/:cid
+func DeleteCommentHandler(ctx *gin.Context) {
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+
+	p, ok := getPublishedPost(ctx)
+	if !ok {
+		return
+	}
+
+	cid, err := strconv.ParseInt(ctx.Param("cid"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	c, err := GetCommentByID(cid)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (c.PostID != p.ID || c.Status != StatusNormal)) {
+		controller.ResponseError(ctx, controller.CodeCommentNotExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("GetCommentByID failed",
+			zap.Int64("cid", cid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	ok, err = canDeleteComment(userID, c, p)
+	if err != nil {
+		logger.Ctx(ctx).Error("canDeleteComment failed",
+			zap.Int64("cid", cid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodeNoPermission)
+		return
+	}
+
+	if err = DeleteComment(cid); err != nil {
+		logger.Ctx(ctx).Error("DeleteComment failed",
+			zap.Int64("cid", cid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, nil)
+}
@@ -0,0 +1,296 @@
+package comment
+
+import (
+	"bookstore/web_app/controller"
+	"bookstore/web_app/dao/mysql/mysqltest"
+	"bookstore/web_app/middlewares"
+	"bookstore/web_app/post"
+	"bookstore/web_app/snowflake"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试里用到的帖子、评论、用户和社区
+const (
+	testPostID       int64 = 1
+	testOtherPostID  int64 = 2
+	testCommentID    int64 = 50
+	testPostAuthorID int64 = 100
+	testAuthorID     int64 = 101
+	testModeratorID  int64 = 200
+	testOtherID      int64 = 300
+	testCommunityID  int64 = 10
+)
+
+var (
+	postColumns    = []string{"post_id", "title", "content", "author_id", "community_id", "status", "create_time"}
+	commentColumns = []string{"comment_id", "post_id", "author_id", "parent_id", "root_id", "content", "status", "create_time"}
+)
+
+// doRequest 调用 handler，userID 不为 0 的时候模拟已经登录，返回响应里的 code
+func doRequest(t *testing.T, route string, handler gin.HandlerFunc, method, path, body string, userID int64) controller.ResCode {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		if userID != 0 {
+			ctx.Set(middlewares.CtxUserIDKey, userID)
+		}
+	})
+	r.Handle(method, route, handler)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp struct {
+		Code controller.ResCode `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp.Code
+}
+
+func expectGetPost(mock sqlmock.Sqlmock, status int32) {
+	mock.ExpectQuery(`from post where post_id = \?`).
+		WithArgs(testPostID).
+		WillReturnRows(sqlmock.NewRows(postColumns).
+			AddRow(testPostID, "title", "content", testPostAuthorID, testCommunityID, status, time.Now()))
+}
+
+func expectGetComment(mock sqlmock.Sqlmock, postID int64, status int32) {
+	mock.ExpectQuery(`from comment where comment_id = \?`).
+		WithArgs(testCommentID).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(testCommentID, postID, testAuthorID, 0, 0, "comment", status, time.Now()))
+}
+
+func TestCreateCommentHandler(t *testing.T) {
+	require.NoError(t, snowflake.Init("2023-07-01", 1))
+
+	testCases := []struct {
+		name   string
+		userID int64
+		body   string
+		mock   func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name:     "not login",
+			body:     `{"content":"hello"}`,
+			mock:     func(mock sqlmock.Sqlmock) {},
+			wantCode: controller.CodeNeedLogin,
+		},
+		{
+			name:   "draft post",
+			userID: testPostAuthorID,
+			body:   `{"content":"hello"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusDraft)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name:   "hidden post",
+			userID: testPostAuthorID,
+			body:   `{"content":"hello"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusHidden)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name:   "reply to comment of another post",
+			userID: testOtherID,
+			body:   `{"content":"hello","parent_id":50}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testOtherPostID, StatusNormal)
+			},
+			wantCode: controller.CodeCommentNotExist,
+		},
+		{
+			name:   "reply to deleted comment",
+			userID: testOtherID,
+			body:   `{"content":"hello","parent_id":50}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusDeleted)
+			},
+			wantCode: controller.CodeCommentNotExist,
+		},
+		{
+			name:   "reply",
+			userID: testOtherID,
+			body:   `{"content":"hello","parent_id":50}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusNormal)
+				// 回复挂在楼层的第一条评论下面
+				mock.ExpectExec(`insert into comment`).
+					WithArgs(sqlmock.AnyArg(), testPostID, testOtherID, testCommentID, testCommentID, "hello", StatusNormal).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantCode: controller.CodeSuccess,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post/:id/comments", CreateCommentHandler,
+				http.MethodPost, "/post/1/comments", tc.body, tc.userID)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestGetCommentListHandler(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name: "hidden post",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusHidden)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name: "first comments with reply counts",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				mock.ExpectQuery(`from comment\s+where post_id = \? and root_id = \?`).
+					WithArgs(testPostID, 0, 0, 11).
+					WillReturnRows(sqlmock.NewRows(commentColumns).
+						AddRow(testCommentID, testPostID, testAuthorID, 0, 0, "comment", StatusNormal, time.Now()))
+				mock.ExpectQuery(`from user where user_id in`).
+					WithArgs(testAuthorID).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "username"}).AddRow(testAuthorID, "author"))
+				// 回复数要带上 post_id 才能用到索引
+				mock.ExpectQuery(`from comment\s+where post_id = \? and root_id in \(\?\) and status = \?`).
+					WithArgs(testPostID, testCommentID, StatusNormal).
+					WillReturnRows(sqlmock.NewRows([]string{"root_id", "cnt"}).AddRow(testCommentID, 3))
+			},
+			wantCode: controller.CodeSuccess,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post/:id/comments", GetCommentListHandler,
+				http.MethodGet, "/post/1/comments", "", 0)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestDeleteCommentHandler(t *testing.T) {
+	expectIsModerator := func(mock sqlmock.Sqlmock, userID int64, isModerator bool) {
+		count := 0
+		if isModerator {
+			count = 1
+		}
+		mock.ExpectQuery(`from community_moderator`).
+			WithArgs(testCommunityID, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(count))
+	}
+	expectDelete := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(`update comment set status = \?`).
+			WithArgs(StatusDeleted, testCommentID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	testCases := []struct {
+		name   string
+		userID int64
+		mock   func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name:   "others",
+			userID: testOtherID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusNormal)
+				expectIsModerator(mock, testOtherID, false)
+			},
+			wantCode: controller.CodeNoPermission,
+		},
+		{
+			name:   "comment author",
+			userID: testAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusNormal)
+				expectDelete(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "post author",
+			userID: testPostAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusNormal)
+				expectDelete(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "moderator",
+			userID: testModeratorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusNormal)
+				expectIsModerator(mock, testModeratorID, true)
+				expectDelete(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "already deleted",
+			userID: testAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusPublished)
+				expectGetComment(mock, testPostID, StatusDeleted)
+			},
+			wantCode: controller.CodeCommentNotExist,
+		},
+		{
+			name:   "hidden post",
+			userID: testPostAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, post.StatusHidden)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post/:id/comments/:cid", DeleteCommentHandler,
+				http.MethodDelete, "/post/1/comments/50", "", tc.userID)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
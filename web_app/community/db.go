@@ -3,13 +3,20 @@ package community
 import (
 	"bookstore/web_app/code"
 	"bookstore/web_app/dao/mysql"
+	"bookstore/web_app/snowflake"
 	"database/sql"
+	"errors"
 
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 var db = mysql.GetDBConn()
 
+// errDupEntry MySQL 唯一索引冲突的错误码
+const errDupEntry = 1062
+
 func getCommunityList() ([]DBCommunity, error) {
 	sqlStr := "select community_id, community_name from community"
 
@@ -26,10 +33,22 @@ func getCommunityList() ([]DBCommunity, error) {
 	return res, nil
 }
 
+// getCommunityListAfter 按照 community_id 正序查询 id 大于 afterID 的 size 个社区
+func getCommunityListAfter(afterID, size int64) ([]DBCommunity, error) {
+	sqlStr := `select community_id, community_name from community
+where community_id > ?
+order by community_id
+limit ?`
+
+	res := make([]DBCommunity, 0, size)
+	err := db.Select(&res, sqlStr, afterID, size)
+	return res, err
+}
+
 func GetCommunityDetailByID(id int64) (DBCommunity, error) {
-	sqlStr := `select community_id, community_name, introduction, create_time
+	sqlStr := `select community_id, community_name, introduction, private, create_time
 from community
-where id = ?`
+where community_id = ?`
 
 	var res DBCommunity
 	err := db.Get(&res, sqlStr, id)
@@ -43,3 +62,138 @@ where id = ?`
 
 	return res, nil
 }
+
+// GetCommunitiesByIDs 一次查询多个社区，不存在的社区会被跳过，结果的顺序不固定
+func GetCommunitiesByIDs(ids []int64) ([]DBCommunity, error) {
+	if len(ids) == 0 {
+		return []DBCommunity{}, nil
+	}
+	sqlStr := `select community_id, community_name, introduction, private, create_time
+from community
+where community_id in (?)`
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]DBCommunity, 0, len(ids))
+	err = db.Select(&res, db.Rebind(query), args...)
+	return res, err
+}
+
+// IsModerator 判断用户是不是社区的版主
+func IsModerator(communityID, userID int64) (bool, error) {
+	sqlStr := `select count(1) from community_moderator where community_id = ? and user_id = ?`
+
+	var count int
+	err := db.Get(&count, sqlStr, communityID, userID)
+	return count > 0, err
+}
+
+// checkCommunityNameExist 检查社区名是否已经被使用
+func checkCommunityNameExist(name string) error {
+	sqlStr := `select count(1) from community where community_name = ?`
+
+	var count int
+	if err := db.Get(&count, sqlStr, name); err != nil {
+		return err
+	}
+	if count > 0 {
+		return code.ErrorCommunityExist
+	}
+	return nil
+}
+
+// insertCommunity 创建社区，返回新的 community_id
+// 社区名已经存在的时候返回 code.ErrorCommunityExist
+func insertCommunity(c DBCommunity) (int64, error) {
+	id := snowflake.GenID()
+	sqlStr := `insert into community(community_id, community_name, introduction, private) values (?, ?, ?, ?)`
+
+	_, err := db.Exec(sqlStr, id, c.CommunityName, c.Introduction, c.Private)
+	return id, duplicateNameErr(err)
+}
+
+// updateCommunity 修改社区的名字、简介和是否私有
+// 社区名已经存在的时候返回 code.ErrorCommunityExist
+func updateCommunity(c DBCommunity) error {
+	sqlStr := `update community set community_name = ?, introduction = ?, private = ? where community_id = ?`
+
+	_, err := db.Exec(sqlStr, c.CommunityName, c.Introduction, c.Private, c.CommunityID)
+	return duplicateNameErr(err)
+}
+
+// duplicateNameErr checkCommunityNameExist 之后并发的请求还是可能用了同一个社区名，
+// 这时候唯一索引冲突，也当成社区名已经存在
+func duplicateNameErr(err error) error {
+	var mysqlErr *driver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDupEntry {
+		return code.ErrorCommunityExist
+	}
+	return err
+}
+
+// insertMember 加入社区，已经是成员的话什么都不做
+func insertMember(communityID, userID int64) error {
+	sqlStr := `insert ignore into community_member(community_id, user_id) values (?, ?)`
+
+	_, err := db.Exec(sqlStr, communityID, userID)
+	return err
+}
+
+// deleteMember 退出社区，版主退出社区之后也不再是版主
+func deleteMember(communityID, userID int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	sqlStr := `delete from community_member where community_id = ? and user_id = ?`
+	if _, err = tx.Exec(sqlStr, communityID, userID); err != nil {
+		return err
+	}
+	sqlStr = `delete from community_moderator where community_id = ? and user_id = ?`
+	if _, err = tx.Exec(sqlStr, communityID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isMember 判断用户是不是社区的成员
+func isMember(communityID, userID int64) (bool, error) {
+	sqlStr := `select count(1) from community_member where community_id = ? and user_id = ?`
+
+	var count int
+	err := db.Get(&count, sqlStr, communityID, userID)
+	return count > 0, err
+}
+
+// getUserCommunityIDs 查询用户加入的社区
+func getUserCommunityIDs(userID int64) ([]int64, error) {
+	sqlStr := `select community_id from community_member where user_id = ? order by community_id`
+
+	ids := make([]int64, 0)
+	err := db.Select(&ids, sqlStr, userID)
+	return ids, err
+}
+
+// insertModerator 设置版主，已经是版主的话什么都不做
+func insertModerator(communityID, userID int64) error {
+	sqlStr := `insert ignore into community_moderator(community_id, user_id) values (?, ?)`
+
+	_, err := db.Exec(sqlStr, communityID, userID)
+	return err
+}
+
+// deleteModerator 取消版主
+func deleteModerator(communityID, userID int64) error {
+	sqlStr := `delete from community_moderator where community_id = ? and user_id = ?`
+
+	_, err := db.Exec(sqlStr, communityID, userID)
+	return err
+}
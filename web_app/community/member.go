@@ -0,0 +1,99 @@
+package community
+
+import (
+	"bookstore/web_app/code"
+	"bookstore/web_app/dao/redis"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memberCacheExpiration 用户加入的社区的缓存时间
+const memberCacheExpiration = 30 * time.Minute
+
+// GetUserCommunityIDs 查询用户加入的社区，先查 redis 缓存，没有的话查 MySQL 并写回缓存
+func GetUserCommunityIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ids, ok, err := redis.GetUserCommunities(ctx, userID)
+	if err != nil {
+		// 缓存出问题不影响查询
+		zap.L().Warn("redis.GetUserCommunities failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	if ok {
+		return ids, nil
+	}
+
+	ids, err = getUserCommunityIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = redis.SetUserCommunities(ctx, userID, ids, memberCacheExpiration); err != nil {
+		zap.L().Warn("redis.SetUserCommunities failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return ids, nil
+}
+
+// IsMember 判断用户是不是社区的成员
+func IsMember(ctx context.Context, communityID, userID int64) (bool, error) {
+	ids, err := GetUserCommunityIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == communityID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanPost 判断用户能不能在社区发帖，私有社区只有成员可以发帖
+// 直接查 MySQL，刚加入社区就要能发帖，不能受缓存影响
+// 社区不存在的时候返回 code.ErrorInvalidID
+func CanPost(communityID, userID int64) (bool, error) {
+	c, err := GetCommunityDetailByID(communityID)
+	if err != nil {
+		return false, err
+	}
+	if !c.Private {
+		return true, nil
+	}
+	return isMember(communityID, userID)
+}
+
+// Join 加入社区
+func Join(ctx context.Context, communityID, userID int64) error {
+	if err := insertMember(communityID, userID); err != nil {
+		return err
+	}
+	// 已经加入成功了，缓存出问题不能再返回错误
+	if err := redis.AddUserCommunity(ctx, userID, communityID); err != nil {
+		zap.L().Warn("redis.AddUserCommunity failed",
+			zap.Int64("community_id", communityID),
+			zap.Int64("user_id", userID),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// Leave 退出社区，同时取消版主
+func Leave(ctx context.Context, communityID, userID int64) error {
+	if err := deleteMember(communityID, userID); err != nil {
+		return err
+	}
+	// 已经退出成功了，缓存出问题不能再返回错误
+	if err := redis.RemoveUserCommunity(ctx, userID, communityID); err != nil {
+		zap.L().Warn("redis.RemoveUserCommunity failed",
+			zap.Int64("community_id", communityID),
+			zap.Int64("user_id", userID),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// isNotExist 社区不存在
+func isNotExist(err error) bool {
+	return errors.Is(err, code.ErrorInvalidID) || errors.Is(err, sql.ErrNoRows)
+}
@@ -7,6 +7,21 @@ type DBCommunity struct {
 	CommunityID   int64     `json:"community_id" db:"community_id"`
 	CommunityName string    `json:"community_name" db:"community_name"`
 	Introduction  string    `json:"introduction" db:"introduction"`
+	Private       bool      `json:"private" db:"private"` // 私有社区只有成员可以发帖
 	CreatTime     time.Time `json:"creat_time" db:"create_time"`
 	UpdateTime    time.Time `json:"update_time" db:"update_time"`
 }
+
+// communityReq 创建社区的请求参数
+type communityReq struct {
+	CommunityName string `json:"community_name" binding:"required,max=128"`
+	Introduction  string `json:"introduction" binding:"required,max=256"`
+	Private       bool   `json:"private"`
+}
+
+// communityUpdateReq 修改社区的请求参数，没有传的字段不修改
+type communityUpdateReq struct {
+	CommunityName *string `json:"community_name" binding:"omitempty,min=1,max=128"`
+	Introduction  *string `json:"introduction" binding:"omitempty,max=256"`
+	Private       *bool   `json:"private"`
+}
@@ -1,7 +1,15 @@
 package community
 
 import (
+	"bookstore/web_app/code"
 	"bookstore/web_app/controller"
+	"bookstore/web_app/logger"
+	"bookstore/web_app/pkg/cursor"
+	"bookstore/web_app/search"
+	"bookstore/web_app/user"
+	"context"
+	"errors"
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -17,12 +25,56 @@ func GetCommunityList() ([]DBCommunity, error) {
 	return getCommunityList()
 }
 
+// GetCommunityListByCursor 从游标的位置开始按照 community_id 获取社区列表
+func GetCommunityListByCursor(c cursor.Cursor, size int64) (cursor.Page, error) {
+	// 多查一条用来判断是否还有下一页
+	list, err := getCommunityListAfter(c.ID, size+1)
+	if err != nil {
+		return cursor.Page{}, err
+	}
+
+	page := cursor.Page{List: list}
+	if int64(len(list)) > size {
+		list = list[:size]
+		page.List = list
+		page.HasMore = true
+		page.NextCursor = cursor.Cursor{ID: list[len(list)-1].CommunityID}.Encode()
+	}
+	return page, nil
+}
+
+// maxPageSize 游标分页每一页最多的社区数
+const maxPageSize = 100
+
+// GetCommunityConf 社区列表
+// 带了 cursor 参数（第一页传空字符串）的时候按照 size 使用游标分页，否则返回所有社区
 func GetCommunityConf(ctx *gin.Context) {
+	if cursorStr, ok := ctx.GetQuery("cursor"); ok {
+		c, err := cursor.Decode(cursorStr)
+		if err != nil {
+			controller.ResponseError(ctx, controller.CodeInvalidParam)
+			return
+		}
+		size, err := strconv.ParseInt(ctx.DefaultQuery("size", "10"), 10, 64)
+		if err != nil || size < 1 || size > maxPageSize {
+			controller.ResponseError(ctx, controller.CodeInvalidParam)
+			return
+		}
+		data, err := GetCommunityListByCursor(c, size)
+		if err != nil {
+			logger.Ctx(ctx).Error("GetCommunityListByCursor() failed", zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			return
+		}
+		controller.ResponseSuccess(ctx, data)
+		return
+	}
+
 	// 查询到所有的社区 (community_id, community_name)
 	// 以列表的形式返回
 	data, err := GetCommunityList()
 	if err != nil {
-		zap.L().Error("GetCommunityList() failed", zap.Error(err))
+		logger.Ctx(ctx).Error("GetCommunityList() failed", zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy) // 不轻易把服务端报错暴露给外面
 		return
 	}
@@ -42,10 +94,348 @@ func GetCommunityDetail(ctx *gin.Context) {
 
 	data, err := GetCommunityDetailByID(id)
 	if err != nil {
-		zap.L().Error("GetCommunityDetail failed", zap.Error(err))
+		logger.Ctx(ctx).Error("GetCommunityDetail failed", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, data)
+}
+
+// requireAdmin 只有管理员才能继续，返回 false 的时候已经写好了响应
+func requireAdmin(ctx *gin.Context) bool {
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return false
+	}
+	ok, err := user.IsAdmin(userID)
+	if err != nil {
+		logger.Ctx(ctx).Error("user.IsAdmin failed", zap.Int64("user_id", userID), zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return false
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodeNoPermission)
+		return false
+	}
+	return true
+}
+
+// getCommunityFromPath 查询路径里的社区，返回 false 的时候已经写好了响应
+func getCommunityFromPath(ctx *gin.Context) (DBCommunity, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return DBCommunity{}, false
+	}
+	c, err := GetCommunityDetailByID(id)
+	if isNotExist(err) {
+		controller.ResponseError(ctx, controller.CodeCommunityNotExist)
+		return DBCommunity{}, false
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("GetCommunityDetailByID failed", zap.Int64("community_id", id), zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return DBCommunity{}, false
+	}
+	return c, true
+}
+
+// CreateCommunityHandler 创建社区，只有管理员可以创建
+// POST /api/v1/community
+func CreateCommunityHandler(ctx *gin.Context) {
+	req := communityReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.Ctx(ctx).Error("create community with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	err := checkCommunityNameExist(req.CommunityName)
+	if errors.Is(err, code.ErrorCommunityExist) {
+		controller.ResponseError(ctx, controller.CodeCommunityExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("checkCommunityNameExist failed", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	id, err := insertCommunity(DBCommunity{
+		CommunityName: req.CommunityName,
+		Introduction:  req.Introduction,
+		Private:       req.Private,
+	})
+	if errors.Is(err, code.ErrorCommunityExist) {
+		controller.ResponseError(ctx, controller.CodeCommunityExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("insertCommunity failed", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	search.IndexDocument(ctx.Request.Context(), search.Document{
+		Type:    search.TypeCommunity,
+		ID:      id,
+		Title:   req.CommunityName,
+		Content: req.Introduction,
+	})
+
+	controller.ResponseSuccess(ctx, gin.H{"community_id": id})
+}
+
+// UpdateCommunityHandler 修改社区，只有管理员可以修改
+// PUT /api/v1/community/:id
+func UpdateCommunityHandler(ctx *gin.Context) {
+	req := communityUpdateReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.Ctx(ctx).Error("update community with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	if !requireAdmin(ctx) {
+		return
+	}
+	c, ok := getCommunityFromPath(ctx)
+	if !ok {
+		return
+	}
+
+	if req.CommunityName != nil && *req.CommunityName != c.CommunityName {
+		err := checkCommunityNameExist(*req.CommunityName)
+		if errors.Is(err, code.ErrorCommunityExist) {
+			controller.ResponseError(ctx, controller.CodeCommunityExist)
+			return
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error("checkCommunityNameExist failed", zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			return
+		}
+		c.CommunityName = *req.CommunityName
+	}
+	if req.Introduction != nil {
+		c.Introduction = *req.Introduction
+	}
+	if req.Private != nil {
+		c.Private = *req.Private
+	}
+
+	err := updateCommunity(c)
+	if errors.Is(err, code.ErrorCommunityExist) {
+		controller.ResponseError(ctx, controller.CodeCommunityExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("updateCommunity failed", zap.Int64("community_id", c.CommunityID), zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	search.IndexDocument(ctx.Request.Context(), search.Document{
+		Type:    search.TypeCommunity,
+		ID:      c.CommunityID,
+		Title:   c.CommunityName,
+		Content: c.Introduction,
+	})
+
+	controller.ResponseSuccess(ctx, c)
+}
+
+// JoinCommunityHandler 当前用户加入社区，私有社区只能由管理员或者版主添加成员
+// POST /api/v1/community/:id/members
+func JoinCommunityHandler(ctx *gin.Context) {
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+	c, ok := getCommunityFromPath(ctx)
+	if !ok {
+		return
+	}
+	if c.Private {
+		controller.ResponseError(ctx, controller.CodeNoPermission)
+		return
+	}
+
+	changeMembership(ctx, c.CommunityID, userID, Join)
+}
+
+// LeaveCommunityHandler 当前用户退出社区，版主退出之后也不再是版主
+// DELETE /api/v1/community/:id/members
+func LeaveCommunityHandler(ctx *gin.Context) {
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+	c, ok := getCommunityFromPath(ctx)
+	if !ok {
+		return
+	}
+
+	changeMembership(ctx, c.CommunityID, userID, Leave)
+}
+
+// AddMemberHandler 把用户加入社区，只有管理员和社区的版主可以添加
+// 用户不存在或者已经注销的时候返回 CodeUserNotExist
+// PUT /api/v1/community/:id/members/:uid
+func AddMemberHandler(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("uid"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	operatorID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+	c, ok := getCommunityFromPath(ctx)
+	if !ok {
+		return
+	}
+
+	ok, err = canManageMembers(c.CommunityID, operatorID)
+	if err != nil {
+		logger.Ctx(ctx).Error("canManageMembers failed",
+			zap.Int64("community_id", c.CommunityID),
+			zap.Int64("user_id", operatorID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodeNoPermission)
+		return
+	}
+
+	active, err := user.IsActive(uid)
+	if err != nil {
+		logger.Ctx(ctx).Error("user.IsActive failed", zap.Int64("user_id", uid), zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+	if !active {
+		controller.ResponseError(ctx, controller.CodeUserNotExist)
+		return
+	}
+
+	changeMembership(ctx, c.CommunityID, uid, Join)
+}
+
+// canManageMembers 管理员和社区的版主可以管理社区成员
+func canManageMembers(communityID, userID int64) (bool, error) {
+	ok, err := user.IsAdmin(userID)
+	if err != nil || ok {
+		return ok, err
+	}
+	return IsModerator(communityID, userID)
+}
+
+func changeMembership(ctx *gin.Context, communityID, userID int64, fn func(ctx context.Context, communityID, userID int64) error) {
+	if err := fn(ctx.Request.Context(), communityID, userID); err != nil {
+		logger.Ctx(ctx).Error("change membership failed",
+			zap.Int64("community_id", communityID),
+			zap.Int64("user_id", userID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, nil)
+}
+
+// GetUserCommunitiesHandler 查询用户加入的社区
+// GET /api/v1/users/:id/communities
+func GetUserCommunitiesHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	ids, err := GetUserCommunityIDs(ctx.Request.Context(), userID)
+	if err != nil {
+		logger.Ctx(ctx).Error("GetUserCommunityIDs failed", zap.Int64("user_id", userID), zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+	data, err := GetCommunitiesByIDs(ids)
+	if err != nil {
+		logger.Ctx(ctx).Error("GetCommunitiesByIDs failed", zap.Int64("user_id", userID), zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy)
 		return
 	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].CommunityID < data[j].CommunityID
+	})
 
 	controller.ResponseSuccess(ctx, data)
 }
+
+// AddModeratorHandler 设置版主，版主同时会加入社区，只有管理员可以设置
+// 用户不存在或者已经注销的时候返回 CodeUserNotExist
+// PUT /api/v1/community/:id/moderators/:uid
+func AddModeratorHandler(ctx *gin.Context) {
+	changeModerator(ctx, func(ctx context.Context, communityID, userID int64) error {
+		ok, err := user.IsActive(userID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return code.ErrorUserNotExist
+		}
+		if err = insertModerator(communityID, userID); err != nil {
+			return err
+		}
+		return Join(ctx, communityID, userID)
+	})
+}
+
+// RemoveModeratorHandler 取消版主，只有管理员可以取消
+// DELETE /api/v1/community/:id/moderators/:uid
+func RemoveModeratorHandler(ctx *gin.Context) {
+	changeModerator(ctx, func(ctx context.Context, communityID, userID int64) error {
+		return deleteModerator(communityID, userID)
+	})
+}
+
+func changeModerator(ctx *gin.Context, fn func(ctx context.Context, communityID, userID int64) error) {
+	uid, err := strconv.ParseInt(ctx.Param("uid"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	if !requireAdmin(ctx) {
+		return
+	}
+	c, ok := getCommunityFromPath(ctx)
+	if !ok {
+		return
+	}
+
+	err = fn(ctx.Request.Context(), c.CommunityID, uid)
+	if errors.Is(err, code.ErrorUserNotExist) {
+		controller.ResponseError(ctx, controller.CodeUserNotExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("change moderator failed",
+			zap.Int64("community_id", c.CommunityID),
+			zap.Int64("user_id", uid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, nil)
+}
@@ -0,0 +1,287 @@
+package community
+
+import (
+	"bookstore/web_app/conf"
+	"bookstore/web_app/controller"
+	"bookstore/web_app/dao/mysql/mysqltest"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/middlewares"
+	"bookstore/web_app/user"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试里用到的社区和用户
+const (
+	testCommunityID int64 = 10
+	testUserID      int64 = 100
+	testAdminID     int64 = 1
+)
+
+// newTestRedis 启动一个进程内的 miniredis，并且让 redis 包连上它
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+	require.NoError(t, redis.Init(&conf.RedisConfig{Host: mr.Host(), Port: port}))
+	t.Cleanup(redis.Close)
+	return mr
+}
+
+// doRequest 调用 handler，userID 不为 0 的时候模拟已经登录，返回响应里的 code
+func doRequest(t *testing.T, route string, handler gin.HandlerFunc, method, path string, userID int64) controller.ResCode {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		if userID != 0 {
+			ctx.Set(middlewares.CtxUserIDKey, userID)
+		}
+	})
+	r.Handle(method, route, handler)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp struct {
+		Code controller.ResCode `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp.Code
+}
+
+func expectGetCommunity(mock sqlmock.Sqlmock, private bool) {
+	mock.ExpectQuery(`from community\s+where community_id = \?`).
+		WithArgs(testCommunityID).
+		WillReturnRows(sqlmock.NewRows([]string{"community_id", "community_name", "introduction", "private", "create_time"}).
+			AddRow(testCommunityID, "go", "", private, time.Now()))
+}
+
+func TestChangeMembership_CacheDown(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler gin.HandlerFunc
+		method  string
+		mock    func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:    "join",
+			handler: JoinCommunityHandler,
+			method:  http.MethodPost,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`insert ignore into community_member`).
+					WithArgs(testCommunityID, testUserID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:    "leave",
+			handler: LeaveCommunityHandler,
+			method:  http.MethodDelete,
+			mock: func(mock sqlmock.Sqlmock) {
+				// 版主退出社区之后也不再是版主
+				mock.ExpectBegin()
+				mock.ExpectExec(`delete from community_member`).
+					WithArgs(testCommunityID, testUserID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`delete from community_moderator`).
+					WithArgs(testCommunityID, testUserID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// MySQL 已经改成功了，redis 出问题也要返回成功
+			newTestRedis(t).Close()
+			mock := mysqltest.New(t)
+			expectGetCommunity(mock, false)
+			tc.mock(mock)
+
+			code := doRequest(t, "/community/:id/members", tc.handler, tc.method, "/community/10/members", testUserID)
+			assert.Equal(t, controller.CodeSuccess, code)
+		})
+	}
+}
+
+func expectAdmin(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`select role from user`).
+		WithArgs(testAdminID, user.StatusNormal).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(user.RoleAdmin))
+}
+
+func TestAddModeratorHandler(t *testing.T) {
+	expectIsActive := func(mock sqlmock.Sqlmock, active bool) {
+		count := 0
+		if active {
+			count = 1
+		}
+		mock.ExpectQuery(`select count\(1\) from user where user_id = \? and status = \?`).
+			WithArgs(testUserID, user.StatusNormal).
+			WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(count))
+	}
+
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name: "user not exist",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectAdmin(mock)
+				expectGetCommunity(mock, false)
+				expectIsActive(mock, false)
+			},
+			wantCode: controller.CodeUserNotExist,
+		},
+		{
+			name: "success",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectAdmin(mock)
+				expectGetCommunity(mock, false)
+				expectIsActive(mock, true)
+				mock.ExpectExec(`insert ignore into community_moderator`).
+					WithArgs(testCommunityID, testUserID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`insert ignore into community_member`).
+					WithArgs(testCommunityID, testUserID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantCode: controller.CodeSuccess,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestRedis(t)
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/community/:id/moderators/:uid", AddModeratorHandler,
+				http.MethodPut, "/community/10/moderators/100", testAdminID)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestJoinCommunityHandler_Private(t *testing.T) {
+	newTestRedis(t)
+	mock := mysqltest.New(t)
+	// 私有社区不能自己加入
+	expectGetCommunity(mock, true)
+
+	code := doRequest(t, "/community/:id/members", JoinCommunityHandler,
+		http.MethodPost, "/community/10/members", testUserID)
+	assert.Equal(t, controller.CodeNoPermission, code)
+}
+
+func TestAddMemberHandler(t *testing.T) {
+	const (
+		testModeratorID int64 = 200
+		testOtherID     int64 = 300
+	)
+	expectNotAdmin := func(mock sqlmock.Sqlmock, userID int64) {
+		mock.ExpectQuery(`select role from user`).
+			WithArgs(userID, user.StatusNormal).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(user.RoleUser))
+	}
+	expectIsModerator := func(mock sqlmock.Sqlmock, userID int64, isModerator bool) {
+		count := 0
+		if isModerator {
+			count = 1
+		}
+		mock.ExpectQuery(`from community_moderator`).
+			WithArgs(testCommunityID, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(count))
+	}
+	expectIsActive := func(mock sqlmock.Sqlmock, active bool) {
+		count := 0
+		if active {
+			count = 1
+		}
+		mock.ExpectQuery(`select count\(1\) from user where user_id = \? and status = \?`).
+			WithArgs(testUserID, user.StatusNormal).
+			WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(count))
+	}
+	expectJoin := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(`insert ignore into community_member`).
+			WithArgs(testCommunityID, testUserID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	testCases := []struct {
+		name       string
+		operatorID int64
+		mock       func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name:       "others",
+			operatorID: testOtherID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetCommunity(mock, true)
+				expectNotAdmin(mock, testOtherID)
+				expectIsModerator(mock, testOtherID, false)
+			},
+			wantCode: controller.CodeNoPermission,
+		},
+		{
+			name:       "user not exist",
+			operatorID: testModeratorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetCommunity(mock, true)
+				expectNotAdmin(mock, testModeratorID)
+				expectIsModerator(mock, testModeratorID, true)
+				expectIsActive(mock, false)
+			},
+			wantCode: controller.CodeUserNotExist,
+		},
+		{
+			name:       "moderator",
+			operatorID: testModeratorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetCommunity(mock, true)
+				expectNotAdmin(mock, testModeratorID)
+				expectIsModerator(mock, testModeratorID, true)
+				expectIsActive(mock, true)
+				expectJoin(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:       "admin",
+			operatorID: testAdminID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetCommunity(mock, true)
+				expectAdmin(mock)
+				expectIsActive(mock, true)
+				expectJoin(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestRedis(t)
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/community/:id/members/:uid", AddMemberHandler,
+				http.MethodPut, "/community/10/members/100", tc.operatorID)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package conf
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -20,9 +21,12 @@ type AppConfig struct {
 	MachineID int64  `mapstructure:"machine_id"`
 	Port      int    `mapstructure:"port"`
 
-	*LogConfig   `mapstructure:"log"`
-	*MysqlConfig `mapstructure:"mysql"`
-	*RedisConfig `mapstructure:"log"`
+	*LogConfig      `mapstructure:"log"`
+	*MysqlConfig    `mapstructure:"mysql"`
+	*RedisConfig    `mapstructure:"redis"`
+	*VoteConfig     `mapstructure:"vote"`
+	*SearchConfig   `mapstructure:"search"`
+	*PasswordConfig `mapstructure:"password"`
 }
 
 type LogConfig struct {
@@ -51,6 +55,29 @@ type RedisConfig struct {
 	PoolSize int    `mapstructure:"pool_size"`
 }
 
+type VoteConfig struct {
+	// Window 发帖之后允许投票的时间，超过之后帖子的票数和分数不再变化，为 0 表示不限制
+	Window time.Duration `mapstructure:"window"`
+}
+
+type SearchConfig struct {
+	// Backend 搜索的实现，memory 是进程内的倒排索引，mysql 使用 FULLTEXT 索引
+	Backend string `mapstructure:"backend"`
+}
+
+type PasswordConfig struct {
+	// Algorithm 新密码使用的算法，argon2id 或者 bcrypt，修改之后旧密码会在登录的时候重新加密
+	Algorithm  string `mapstructure:"algorithm"`
+	BcryptCost int    `mapstructure:"bcrypt_cost"`
+	Argon2Time uint32 `mapstructure:"argon2_time"`
+	// Argon2Memory 单位是 KiB
+	Argon2Memory  uint32 `mapstructure:"argon2_memory"`
+	Argon2Threads uint8  `mapstructure:"argon2_threads"`
+	// MaxConcurrency 最多同时加密或者验证多少个密码，argon2id 最多占用 Argon2Memory * MaxConcurrency 的内存
+	// 为 0 的时候和 CPU 数量一样
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+}
+
 func Init() {
 	once.Do(func() {
 		// 方式1: 直接指定配置文件路径（相对路径或者绝对路径）
@@ -12,17 +12,34 @@ const (
 
 	CodeNeedLogin
 	CodeInvalidToken
+
+	CodePostNotExist
+	CodeVoteTimeExpire
+	CodeVoteRepeated
+	CodeNoPermission
+	CodeCommentNotExist
+	CodeCommunityExist
+	CodeCommunityNotExist
+	CodeNotCommunityMember
 )
 
 var codeMsgMap = map[ResCode]string{
-	CodeSuccess:         "success",
-	CodeInvalidParam:    "请求参数错误",
-	CodeUserExist:       "用户名已存在",
-	CodeUserNotExist:    "用户名不存在",
-	CodeInvalidPassword: "用户名或密码错误",
-	CodeServerBusy:      "服务繁忙",
-	CodeInvalidToken:    "无效的 token",
-	CodeNeedLogin:       "需要登录",
+	CodeSuccess:            "success",
+	CodeInvalidParam:       "请求参数错误",
+	CodeUserExist:          "用户名已存在",
+	CodeUserNotExist:       "用户名不存在",
+	CodeInvalidPassword:    "用户名或密码错误",
+	CodeServerBusy:         "服务繁忙",
+	CodeInvalidToken:       "无效的 token",
+	CodeNeedLogin:          "需要登录",
+	CodePostNotExist:       "帖子不存在",
+	CodeVoteTimeExpire:     "投票时间已过",
+	CodeVoteRepeated:       "不允许重复投票",
+	CodeNoPermission:       "没有权限",
+	CodeCommentNotExist:    "评论不存在",
+	CodeCommunityExist:     "社区名已存在",
+	CodeCommunityNotExist:  "社区不存在",
+	CodeNotCommunityMember: "私有社区只有成员可以发帖",
 }
 
 func (c ResCode) Msg() string {
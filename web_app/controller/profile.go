@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"bookstore/web_app/code"
+	"bookstore/web_app/logger"
+	"bookstore/web_app/user"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+// updateMeReq 修改资料的请求参数，没有传的字段不修改
+type updateMeReq struct {
+	// Avatar 只允许 http 和 https，避免 javascript: 之类的地址
+	Avatar *string `json:"avatar" binding:"omitempty,http_url,max=256"`
+	Bio    *string `json:"bio" binding:"omitempty,max=256"`
+	// Gender 0 未知 1 男 2 女
+	Gender *int8 `json:"gender" binding:"omitempty,oneof=0 1 2"`
+}
+
+// changePasswordReq 修改密码的请求参数
+type changePasswordReq struct {
+	OldPassword   string `json:"old_password" binding:"required"`
+	NewPassword   string `json:"new_password" binding:"required,min=8"`
+	ReNewPassword string `json:"re_new_password" binding:"required,eqfield=NewPassword"`
+}
+
+// deleteMeReq 注销账号的请求参数，需要再输一次密码
+type deleteMeReq struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// GetMeHandler 查询自己的资料
+// GET /api/v1/me
+func GetMeHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+	p, err := user.GetProfile(ctx.Request.Context(), userID)
+	if err != nil {
+		responseProfileError(ctx, "user.GetProfile failed", userID, err)
+		return
+	}
+	ResponseSuccess(ctx, p)
+}
+
+// UpdateMeHandler 修改自己的头像、简介和性别
+// PUT /api/v1/me
+func UpdateMeHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+	req := &updateMeReq{}
+	if !bindJSON(ctx, req) {
+		return
+	}
+
+	p, err := user.UpdateProfile(ctx.Request.Context(), userID, user.ProfileUpdate{
+		Avatar: req.Avatar,
+		Bio:    req.Bio,
+		Gender: req.Gender,
+	})
+	if err != nil {
+		responseProfileError(ctx, "user.UpdateProfile failed", userID, err)
+		return
+	}
+	ResponseSuccess(ctx, p)
+}
+
+// ChangePasswordHandler 修改密码，所有设备需要重新登录，当前设备使用返回的新 token
+// PUT /api/v1/me/password
+func ChangePasswordHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+	req := &changePasswordReq{}
+	if !bindJSON(ctx, req) {
+		return
+	}
+
+	token, err := user.ChangePassword(ctx.Request.Context(), userID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		responseProfileError(ctx, "user.ChangePassword failed", userID, err)
+		return
+	}
+	// 之前的 token 都失效了，返回新的 token
+	ResponseSuccess(ctx, token)
+}
+
+// DeleteMeHandler 注销账号
+// DELETE /api/v1/me
+func DeleteMeHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+	req := &deleteMeReq{}
+	if !bindJSON(ctx, req) {
+		return
+	}
+
+	if err := user.DeleteAccount(ctx.Request.Context(), userID, req.Password); err != nil {
+		responseProfileError(ctx, "user.DeleteAccount failed", userID, err)
+		return
+	}
+	ResponseSuccess(ctx, nil)
+}
+
+// GetUserProfileHandler 查询别人的公开资料，不返回邮箱
+// GET /api/v1/users/:id
+func GetUserProfileHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, CodeInvalidParam)
+		return
+	}
+	p, err := user.GetProfile(ctx.Request.Context(), userID)
+	if err != nil {
+		responseProfileError(ctx, "user.GetProfile failed", userID, err)
+		return
+	}
+	p.Email = ""
+	ResponseSuccess(ctx, p)
+}
+
+// currentUserID 获取当前登录的用户，返回 false 的时候已经写好了响应
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		ResponseError(ctx, CodeNeedLogin)
+		return 0, false
+	}
+	return userID, true
+}
+
+// bindJSON 解析请求参数，返回 false 的时候已经写好了响应
+func bindJSON(ctx *gin.Context, req any) bool {
+	err := ctx.ShouldBindJSON(req)
+	if err == nil {
+		return true
+	}
+	logger.Ctx(ctx).Error("invalid param", zap.Error(err))
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		ResponseErrorWithMsg(ctx, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		return false
+	}
+	ResponseError(ctx, CodeInvalidParam)
+	return false
+}
+
+func responseProfileError(ctx *gin.Context, msg string, userID int64, err error) {
+	switch {
+	case errors.Is(err, code.ErrorUserNotExist):
+		ResponseError(ctx, CodeUserNotExist)
+	case errors.Is(err, code.ErrorInvalidPassword):
+		ResponseError(ctx, CodeInvalidPassword)
+	default:
+		logger.Ctx(ctx).Error(msg, zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(ctx, CodeServerBusy)
+	}
+}
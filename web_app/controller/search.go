@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"bookstore/web_app/logger"
+	"bookstore/web_app/search"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// searchReq 搜索的请求参数
+type searchReq struct {
+	Q    string `form:"q" binding:"required,max=100"`
+	Type string `form:"type" binding:"omitempty,oneof=post community"`
+	Page int64  `form:"page" binding:"min=1"`
+	Size int64  `form:"size" binding:"min=1,max=50"`
+}
+
+// SearchHandler 搜索帖子的标题、内容和社区的名字
+// GET /api/v1/search?q=&type=post|community&page=1&size=10
+func SearchHandler(ctx *gin.Context) {
+	req := searchReq{Page: 1, Size: 10}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logger.Ctx(ctx).Error("search with invalid param", zap.Error(err))
+		ResponseError(ctx, CodeInvalidParam)
+		return
+	}
+
+	res, err := search.Search(ctx.Request.Context(), search.Query{
+		Q:    req.Q,
+		Type: req.Type,
+		Page: req.Page,
+		Size: req.Size,
+	})
+	if errors.Is(err, search.ErrEmptyQuery) {
+		ResponseError(ctx, CodeInvalidParam)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("search.Search failed", zap.String("q", req.Q), zap.Error(err))
+		ResponseError(ctx, CodeServerBusy)
+		return
+	}
+
+	ResponseSuccess(ctx, res)
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bookstore/web_app/code"
+	"bookstore/web_app/logger"
 	"bookstore/web_app/user"
 	"errors"
 	"net/http"
@@ -16,7 +17,7 @@ import (
 // SignUpReq 定义请求的参数结构体
 type SignUpReq struct {
 	Username   string `json:"username" binding:"required"`
-	Password   string `json:"password" binding:"required"`
+	Password   string `json:"password" binding:"required,min=8"`
 	Email      string `json:"email" bind:"required"`
 	RePassword string `json:"re_password" binding:"required,eqfield=Password"`
 }
@@ -27,7 +28,7 @@ func SignUpHandler(ctx *gin.Context) {
 	err := ctx.ShouldBindJSON(req)
 	if err != nil {
 		// 请求参数有误，直击返回响应
-		zap.L().Error("SignUp with invalid param", zap.Error(err))
+		logger.Ctx(ctx).Error("SignUp with invalid param", zap.Error(err))
 		// 判断 err 是不是 validator.ValidationErrors 错误
 		errs, ok := err.(validator.ValidationErrors)
 		if !ok {
@@ -47,7 +48,7 @@ func SignUpHandler(ctx *gin.Context) {
 		Password: req.Password,
 	})
 	if err != nil {
-		zap.L().Error("sign up failed", zap.Error(err))
+		logger.Ctx(ctx).Error("sign up failed", zap.Error(err))
 		if errors.Is(err, code.ErrorUserExist) {
 			ResponseError(ctx, CodeUserExist)
 			return
@@ -72,7 +73,7 @@ func LoginHandler(ctx *gin.Context) {
 	err := ctx.ShouldBindJSON(l)
 	if err != nil {
 		// 请求参数有误，直接返回响应
-		zap.L().Error("login with invalid param",
+		logger.Ctx(ctx).Error("login with invalid param",
 			zap.String("username", l.UserName), zap.Error(err))
 		// 判断 err 是不是 validator.ValidationErrors 类型
 		errs, ok := err.(*validator.ValidationErrors)
@@ -94,7 +95,7 @@ func LoginHandler(ctx *gin.Context) {
 		Password: l.Password,
 	})
 	if err != nil {
-		zap.L().Error("login failed", zap.Error(err))
+		logger.Ctx(ctx).Error("login failed", zap.Error(err))
 		if errors.Is(err, code.ErrorUserNotExist) {
 			ResponseError(ctx, CodeUserExist)
 			return
@@ -2,23 +2,36 @@ package mysql
 
 import (
 	"bookstore/web_app/conf"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"sync"
 
 	"go.uber.org/zap"
 
-	_ "github.com/go-sql-driver/mysql"
+	driverMysql "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
 var (
 	db   *sqlx.DB
 	once sync.Once
+
+	// connector 真正建立连接的时候使用，Init 根据配置创建，测试的时候可以换成 sqlmock
+	connector   driver.Connector
+	connectorMu sync.RWMutex
 )
 
+// errNotInit 还没有调用 Init 就访问数据库
+var errNotInit = errors.New("mysql: not initialized")
+
+// GetDBConn 返回全局的连接池
+// 各个包在 import 的时候就会调用，这时候不会建立连接，调用 Init 之后才能访问数据库
 func GetDBConn() *sqlx.DB {
 	once.Do(func() {
-		Init()
+		db = sqlx.NewDb(sql.OpenDB(lazyConnector{}), "mysql")
 	})
 
 	return db
@@ -30,18 +43,50 @@ func Init() {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=Local",
 		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName)
 
-	var err error
-	// 也可以使用 MustConnect，连接不成功就 panic
-	db, err = sqlx.Connect("mysql", dsn)
+	mysqlCfg, err := driverMysql.ParseDSN(dsn)
+	if err != nil {
+		panic("init mysql failed, err: " + err.Error())
+	}
+	c, err := driverMysql.NewConnector(mysqlCfg)
 	if err != nil {
+		panic("init mysql failed, err: " + err.Error())
+	}
+	SetConnector(c)
+
+	d := GetDBConn()
+	d.SetMaxOpenConns(cfg.MaxOpenConns)
+	d.SetMaxIdleConns(cfg.MaxIdleConns)
+	// 连不上就 panic，和 MustConnect 一样
+	if err = d.Ping(); err != nil {
 		zap.L().Error("connect db failed, err:", zap.Error(err))
 		panic("init mysql failed, err: " + err.Error())
 	}
+}
 
-	db.SetMaxOpenConns(cfg.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.MaxIdleConns)
+// SetConnector 替换建立连接使用的 driver.Connector，只影响之后新建立的连接
+func SetConnector(c driver.Connector) {
+	connectorMu.Lock()
+	defer connectorMu.Unlock()
+	connector = c
 }
 
 func Close() {
 	_ = db.Close()
 }
+
+// lazyConnector 每次建立连接的时候才去拿当前的 connector
+type lazyConnector struct{}
+
+func (lazyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connectorMu.RLock()
+	c := connector
+	connectorMu.RUnlock()
+	if c == nil {
+		return nil, errNotInit
+	}
+	return c.Connect(ctx)
+}
+
+func (lazyConnector) Driver() driver.Driver {
+	return driverMysql.MySQLDriver{}
+}
@@ -0,0 +1,51 @@
+// Package mysqltest 测试的时候用 sqlmock 代替 MySQL
+//
+//	mock := mysqltest.New(t)
+//	mock.ExpectQuery("select .* from post").WillReturnRows(rows)
+package mysqltest
+
+import (
+	"bookstore/web_app/dao/mysql"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var counter atomic.Int64
+
+// New 之后 mysql.GetDBConn 新建立的连接都会连到返回的 sqlmock 上
+// 测试结束的时候检查预期的 SQL 是不是都执行了
+func New(t *testing.T) sqlmock.Sqlmock {
+	dsn := fmt.Sprintf("mysqltest_%d", counter.Add(1))
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn)
+	require.NoError(t, err)
+
+	// 不保留空闲的连接，不然后面的测试会连到前面测试的 sqlmock 上
+	mysql.GetDBConn().SetMaxIdleConns(0)
+	mysql.SetConnector(connector{drv: mockDB.Driver(), dsn: dsn})
+	t.Cleanup(func() {
+		mysql.SetConnector(nil)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		_ = mockDB.Close()
+	})
+	return mock
+}
+
+type connector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.drv
+}
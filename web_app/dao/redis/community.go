@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// emptySetMember 占位的成员，用来区分没有缓存和用户没有加入任何社区
+const emptySetMember = "0"
+
+// GetUserCommunities 从缓存里查询用户加入的社区，按照 community_id 排序
+// 第二个返回值为 false 表示没有缓存，需要查数据库
+func GetUserCommunities(ctx context.Context, userID int64) ([]int64, bool, error) {
+	members, err := rdb.SMembers(ctx, getUserCommunitiesKey(userID)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(members) == 0 {
+		return nil, false, nil
+	}
+
+	res := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != emptySetMember {
+			res = append(res, m)
+		}
+	}
+	ids, err := parseIDs(res)
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids, true, nil
+}
+
+// SetUserCommunities 缓存用户加入的社区
+func SetUserCommunities(ctx context.Context, userID int64, ids []int64, expiration time.Duration) error {
+	key := getUserCommunitiesKey(userID)
+	members := make([]any, 0, len(ids)+1)
+	members = append(members, emptySetMember)
+	for _, id := range ids {
+		members = append(members, id)
+	}
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.SAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+// saddIfExists 只有缓存存在的时候才加入成员，没有缓存的时候下次查询会从数据库重新加载
+var saddIfExists = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("SADD", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// AddUserCommunity 加入社区之后更新缓存
+// 不能先删缓存再让查询重新加载，并发的查询可能把加入之前的数据写回缓存
+func AddUserCommunity(ctx context.Context, userID, communityID int64) error {
+	return saddIfExists.Run(ctx, rdb, []string{getUserCommunitiesKey(userID)}, communityID).Err()
+}
+
+// RemoveUserCommunity 退出社区之后更新缓存
+func RemoveUserCommunity(ctx context.Context, userID, communityID int64) error {
+	return rdb.SRem(ctx, getUserCommunitiesKey(userID), communityID).Err()
+}
+
+// DelUserCommunities 删除用户加入的社区的缓存，注销账号之后调用
+func DelUserCommunities(ctx context.Context, userID int64) error {
+	return rdb.Del(ctx, getUserCommunitiesKey(userID)).Err()
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserCommunities(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+
+	// 没有缓存
+	_, ok, err := GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 没有加入任何社区也要能缓存
+	require.NoError(t, SetUserCommunities(ctx, 100, nil, time.Minute))
+	ids, ok, err := GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, ids)
+
+	// 覆盖之前的缓存
+	require.NoError(t, SetUserCommunities(ctx, 100, []int64{3, 1, 2}, time.Minute))
+	ids, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	// 有缓存的时候直接修改缓存
+	require.NoError(t, AddUserCommunity(ctx, 100, 4))
+	require.NoError(t, RemoveUserCommunity(ctx, 100, 2))
+	ids, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 3, 4}, ids)
+
+	// 退出所有社区之后还是有缓存
+	for _, id := range []int64{1, 3, 4} {
+		require.NoError(t, RemoveUserCommunity(ctx, 100, id))
+	}
+	ids, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, ids)
+
+	require.NoError(t, DelUserCommunities(ctx, 100))
+	_, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 没有缓存的时候不会只缓存新加入的社区
+	require.NoError(t, AddUserCommunity(ctx, 100, 5))
+	_, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 过期
+	require.NoError(t, SetUserCommunities(ctx, 100, []int64{1}, time.Minute))
+	mr.FastForward(2 * time.Minute)
+	_, ok, err = GetUserCommunities(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,48 @@
+package redis
+
+import "strconv"
+
+// redis key 注意使用命名空间的方式，方便查询和拆分
+const (
+	keyPrefix            = "bookstore:"
+	keyPostTimeZSet      = "post:time"      // zset; 帖子及发帖时间
+	keyPostScoreZSet     = "post:score"     // zset; 帖子及投票的分数
+	keyPostVotedPrefix   = "post:voted:"    // zset; 记录用户及投票类型; 参数是 post_id
+	keyPostCommunityHash = "post:community" // hash; 帖子所属的社区
+
+	keyCommunityPostTimePrefix  = "community:post:time:"  // zset; 社区内的帖子及发帖时间; 参数是 community_id
+	keyCommunityPostScorePrefix = "community:post:score:" // zset; 社区内的帖子及分数; 参数是 community_id
+
+	keyUserCommunitiesPrefix  = "user:communities:"   // set; 用户加入的社区; 参数是 user_id
+	keyUserProfilePrefix      = "user:profile:"       // string; 用户资料的 JSON; 参数是 user_id
+	keyUserTokenVersionPrefix = "user:token_version:" // string; 用户当前的 token 版本; 参数是 user_id
+)
+
+// getRedisKey 给 redis key 加上前缀
+func getRedisKey(key string) string {
+	return keyPrefix + key
+}
+
+func getPostVotedKey(postID int64) string {
+	return getRedisKey(keyPostVotedPrefix + strconv.FormatInt(postID, 10))
+}
+
+func getCommunityPostTimeKey(communityID int64) string {
+	return getRedisKey(keyCommunityPostTimePrefix + strconv.FormatInt(communityID, 10))
+}
+
+func getCommunityPostScoreKey(communityID int64) string {
+	return getRedisKey(keyCommunityPostScorePrefix + strconv.FormatInt(communityID, 10))
+}
+
+func getUserCommunitiesKey(userID int64) string {
+	return getRedisKey(keyUserCommunitiesPrefix + strconv.FormatInt(userID, 10))
+}
+
+func getUserProfileKey(userID int64) string {
+	return getRedisKey(keyUserProfilePrefix + strconv.FormatInt(userID, 10))
+}
+
+func getUserTokenVersionKey(userID int64) string {
+	return getRedisKey(keyUserTokenVersionPrefix + strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,290 @@
+package redis
+
+import (
+	"bookstore/web_app/code"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// voteMaxRetries 并发投票导致 WATCH 的 key 被修改时的最大重试次数
+const voteMaxRetries = 5
+
+// PostVoteData 帖子的投票数据
+type PostVoteData struct {
+	Up    int64
+	Down  int64
+	Score float64
+}
+
+// 帖子列表的排序方式
+const (
+	OrderTime  = "time"
+	OrderScore = "score"
+)
+
+// CreatePost 记录帖子的发帖时间和分数，帖子要先调用这个方法才能投票和出现在帖子列表里
+// 全站和帖子所在的社区各维护一份
+// 隐藏的帖子重新发布的时候也调用这个方法，分数按照已有的投票计算
+func CreatePost(ctx context.Context, postID, communityID int64, createTime time.Time) error {
+	pid := strconv.FormatInt(postID, 10)
+	votedKey := getPostVotedKey(postID)
+	up, err := rdb.ZCount(ctx, votedKey, "1", "1").Result()
+	if err != nil {
+		return err
+	}
+	down, err := rdb.ZCount(ctx, votedKey, "-1", "-1").Result()
+	if err != nil {
+		return err
+	}
+	timeZ := &redis.Z{
+		Score:  float64(createTime.Unix()),
+		Member: pid,
+	}
+	scoreZ := &redis.Z{
+		Score:  PostScore(up, down, createTime),
+		Member: pid,
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, getRedisKey(keyPostTimeZSet), timeZ)
+		pipe.ZAdd(ctx, getRedisKey(keyPostScoreZSet), scoreZ)
+		pipe.HSet(ctx, getRedisKey(keyPostCommunityHash), pid, communityID)
+		pipe.ZAdd(ctx, getCommunityPostTimeKey(communityID), timeZ)
+		pipe.ZAdd(ctx, getCommunityPostScoreKey(communityID), scoreZ)
+		return nil
+	})
+	return err
+}
+
+// PostMeta 帖子列表需要的帖子信息
+type PostMeta struct {
+	ID          int64
+	CommunityID int64
+	CreateTime  time.Time
+}
+
+// BackfillPosts 把还不在帖子列表里的帖子加进去，已经在列表里的帖子不做修改
+// 返回新加进去的帖子数量
+func BackfillPosts(ctx context.Context, posts []PostMeta) (int, error) {
+	if len(posts) == 0 {
+		return 0, nil
+	}
+	timeKey := getRedisKey(keyPostTimeZSet)
+	cmds := make([]*redis.FloatCmd, 0, len(posts))
+	pipe := rdb.Pipeline()
+	for _, p := range posts {
+		cmds = append(cmds, pipe.ZScore(ctx, timeKey, strconv.FormatInt(p.ID, 10)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	n := 0
+	for i, c := range cmds {
+		if err := c.Err(); err != redis.Nil {
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		p := posts[i]
+		if err := CreatePost(ctx, p.ID, p.CommunityID, p.CreateTime); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RemovePost 把帖子从帖子列表里移除，移除之后不能再投票
+// 投票记录会保留下来，帖子重新发布的时候还能用
+func RemovePost(ctx context.Context, postID, communityID int64) error {
+	pid := strconv.FormatInt(postID, 10)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, getRedisKey(keyPostTimeZSet), pid)
+		pipe.ZRem(ctx, getRedisKey(keyPostScoreZSet), pid)
+		pipe.HDel(ctx, getRedisKey(keyPostCommunityHash), pid)
+		pipe.ZRem(ctx, getCommunityPostTimeKey(communityID), pid)
+		pipe.ZRem(ctx, getCommunityPostScoreKey(communityID), pid)
+		return nil
+	})
+	return err
+}
+
+// GetPostIDsInOrder 按照 order 从大到小返回第 page 页的帖子 id，page 从 1 开始
+// communityID 为 0 表示不区分社区
+func GetPostIDsInOrder(ctx context.Context, order string, communityID, page, size int64) ([]int64, error) {
+	key := getPostOrderKey(order, communityID)
+	start := (page - 1) * size
+	members, err := rdb.ZRevRange(ctx, key, start, start+size-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseIDs(members)
+}
+
+func getPostOrderKey(order string, communityID int64) string {
+	if communityID == 0 {
+		if order == OrderScore {
+			return getRedisKey(keyPostScoreZSet)
+		}
+		return getRedisKey(keyPostTimeZSet)
+	}
+	if order == OrderScore {
+		return getCommunityPostScoreKey(communityID)
+	}
+	return getCommunityPostTimeKey(communityID)
+}
+
+func parseIDs(members []string) ([]int64, error) {
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// VoteForPost 为帖子投票，direction 为 1 表示赞成，-1 表示反对，0 表示取消投票
+// 发帖超过 window 之后不允许再投票，window 为 0 表示不限制
+// changed 为 false 表示投票没有变化，例如没投过票又取消
+func VoteForPost(ctx context.Context, userID, postID int64, direction int8, window time.Duration) (changed bool, err error) {
+	pid := strconv.FormatInt(postID, 10)
+	uid := strconv.FormatInt(userID, 10)
+	timeKey := getRedisKey(keyPostTimeZSet)
+	votedKey := getPostVotedKey(postID)
+
+	// WATCH 投票记录和发帖时间，被并发修改的时候重试
+	// 投票记录保证分数是按照最新的票数计算的，
+	// 发帖时间保证帖子在这期间被 RemovePost 移除之后，不会又被 ZADD 加回帖子列表
+	txf := func(tx *redis.Tx) error {
+		changed = false
+		// 1. 判断投票限制
+		createUnix, err := tx.ZScore(ctx, timeKey, pid).Result()
+		if err == redis.Nil {
+			return code.ErrorPostNotExist
+		}
+		if err != nil {
+			return err
+		}
+		createTime := time.Unix(int64(createUnix), 0)
+		if window > 0 && time.Since(createTime) > window {
+			return code.ErrorVoteTimeExpire
+		}
+		communityID, err := tx.HGet(ctx, getRedisKey(keyPostCommunityHash), pid).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		// 2. 更新投票记录并重新计算分数
+		old, err := tx.ZScore(ctx, votedKey, uid).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if int8(old) == direction {
+			if direction == 0 {
+				// 没投过票又取消，什么都不用做
+				return nil
+			}
+			return code.ErrorVoteRepeated
+		}
+
+		up, err := tx.ZCount(ctx, votedKey, "1", "1").Result()
+		if err != nil {
+			return err
+		}
+		down, err := tx.ZCount(ctx, votedKey, "-1", "-1").Result()
+		if err != nil {
+			return err
+		}
+		up, down = applyVote(up, down, int8(old), direction)
+
+		scoreZ := &redis.Z{
+			Score:  PostScore(up, down, createTime),
+			Member: pid,
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if direction == 0 {
+				pipe.ZRem(ctx, votedKey, uid)
+			} else {
+				pipe.ZAdd(ctx, votedKey, &redis.Z{
+					Score:  float64(direction),
+					Member: uid,
+				})
+			}
+			pipe.ZAdd(ctx, getRedisKey(keyPostScoreZSet), scoreZ)
+			if communityID != 0 {
+				pipe.ZAdd(ctx, getCommunityPostScoreKey(communityID), scoreZ)
+			}
+			return nil
+		})
+		changed = err == nil
+		return err
+	}
+
+	for i := 0; i < voteMaxRetries; i++ {
+		err = rdb.Watch(ctx, txf, votedKey, timeKey)
+		if err != redis.TxFailedErr {
+			return changed, err
+		}
+	}
+	return false, err
+}
+
+// applyVote 把用户之前的投票换成新的投票之后的票数
+func applyVote(up, down int64, old, direction int8) (int64, int64) {
+	switch old {
+	case 1:
+		up--
+	case -1:
+		down--
+	}
+	switch direction {
+	case 1:
+		up++
+	case -1:
+		down++
+	}
+	return up, down
+}
+
+// GetPostVoteData 批量查询帖子的赞成票数、反对票数和分数，返回的结果和 ids 一一对应
+func GetPostVoteData(ctx context.Context, ids []int64) ([]PostVoteData, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	type voteCmds struct {
+		up    *redis.IntCmd
+		down  *redis.IntCmd
+		score *redis.FloatCmd
+	}
+	cmds := make([]voteCmds, 0, len(ids))
+	pipe := rdb.Pipeline()
+	for _, id := range ids {
+		votedKey := getPostVotedKey(id)
+		cmds = append(cmds, voteCmds{
+			up:    pipe.ZCount(ctx, votedKey, "1", "1"),
+			down:  pipe.ZCount(ctx, votedKey, "-1", "-1"),
+			score: pipe.ZScore(ctx, getRedisKey(keyPostScoreZSet), strconv.FormatInt(id, 10)),
+		})
+	}
+	// 没有分数的帖子会返回 redis.Nil，当成 0 分处理
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	res := make([]PostVoteData, 0, len(ids))
+	for _, c := range cmds {
+		res = append(res, PostVoteData{
+			Up:    c.up.Val(),
+			Down:  c.down.Val(),
+			Score: c.score.Val(),
+		})
+	}
+	return res, nil
+}
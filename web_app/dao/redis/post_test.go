@@ -0,0 +1,273 @@
+package redis
+
+import (
+	"bookstore/web_app/code"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis 启动一个进程内的 miniredis，并且让全局的 rdb 连上它
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(Close)
+	return mr
+}
+
+func TestVoteForPost(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, CreatePost(ctx, 1, 10, now))
+	require.NoError(t, CreatePost(ctx, 2, 10, now.Add(-8*24*time.Hour)))
+	window := 7 * 24 * time.Hour
+
+	testCases := []struct {
+		name      string
+		userID    int64
+		postID    int64
+		direction int8
+		wantErr   error
+		// wantChanged 为 false 表示投票记录没有变化
+		wantChanged bool
+		wantVote    PostVoteData
+	}{
+		{
+			name:      "post not exist",
+			userID:    100,
+			postID:    3,
+			direction: 1,
+			wantErr:   code.ErrorPostNotExist,
+		},
+		{
+			name:      "expired",
+			userID:    100,
+			postID:    2,
+			direction: 1,
+			wantErr:   code.ErrorVoteTimeExpire,
+		},
+		{
+			name:      "cancel without vote",
+			userID:    100,
+			postID:    1,
+			direction: 0,
+			wantVote:  PostVoteData{Score: PostScore(0, 0, now)},
+		},
+		{
+			name:        "up",
+			userID:      100,
+			postID:      1,
+			direction:   1,
+			wantChanged: true,
+			wantVote:    PostVoteData{Up: 1, Score: PostScore(1, 0, now)},
+		},
+		{
+			name:      "repeated",
+			userID:    100,
+			postID:    1,
+			direction: 1,
+			wantErr:   code.ErrorVoteRepeated,
+			wantVote:  PostVoteData{Up: 1, Score: PostScore(1, 0, now)},
+		},
+		{
+			name:        "another user down",
+			userID:      101,
+			postID:      1,
+			direction:   -1,
+			wantChanged: true,
+			wantVote:    PostVoteData{Up: 1, Down: 1, Score: PostScore(1, 1, now)},
+		},
+		{
+			name:        "up to down",
+			userID:      100,
+			postID:      1,
+			direction:   -1,
+			wantChanged: true,
+			wantVote:    PostVoteData{Down: 2, Score: PostScore(0, 2, now)},
+		},
+		{
+			name:        "cancel",
+			userID:      101,
+			postID:      1,
+			direction:   0,
+			wantChanged: true,
+			wantVote:    PostVoteData{Down: 1, Score: PostScore(0, 1, now)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed, err := VoteForPost(ctx, tc.userID, tc.postID, tc.direction, window)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantChanged, changed)
+			if tc.wantErr == code.ErrorPostNotExist || tc.wantErr == code.ErrorVoteTimeExpire {
+				return
+			}
+			data, err := GetPostVoteData(ctx, []int64{tc.postID})
+			require.NoError(t, err)
+			assert.Equal(t, []PostVoteData{tc.wantVote}, data)
+		})
+	}
+
+	// 社区里的分数也要跟着更新
+	score, err := rdb.ZScore(ctx, getCommunityPostScoreKey(10), "1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, PostScore(0, 1, now), score)
+}
+
+func TestGetPostIDsInOrder(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	now := time.Now()
+	// 1 最早，3 最晚，2 在社区 20
+	require.NoError(t, CreatePost(ctx, 1, 10, now.Add(-2*time.Hour)))
+	require.NoError(t, CreatePost(ctx, 2, 20, now.Add(-time.Hour)))
+	require.NoError(t, CreatePost(ctx, 3, 10, now))
+	// 给 1 投很多票，让它的分数最高
+	for uid := int64(100); uid < 200; uid++ {
+		_, err := VoteForPost(ctx, uid, 1, 1, 0)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name        string
+		order       string
+		communityID int64
+		page        int64
+		size        int64
+		wantIDs     []int64
+	}{
+		{
+			name:    "time",
+			order:   OrderTime,
+			page:    1,
+			size:    10,
+			wantIDs: []int64{3, 2, 1},
+		},
+		{
+			name:    "default order",
+			page:    1,
+			size:    10,
+			wantIDs: []int64{3, 2, 1},
+		},
+		{
+			name:    "score",
+			order:   OrderScore,
+			page:    1,
+			size:    10,
+			wantIDs: []int64{1, 3, 2},
+		},
+		{
+			name:    "second page",
+			order:   OrderTime,
+			page:    2,
+			size:    2,
+			wantIDs: []int64{1},
+		},
+		{
+			name:    "out of range",
+			order:   OrderTime,
+			page:    3,
+			size:    2,
+			wantIDs: []int64{},
+		},
+		{
+			name:        "community time",
+			order:       OrderTime,
+			communityID: 10,
+			page:        1,
+			size:        10,
+			wantIDs:     []int64{3, 1},
+		},
+		{
+			name:        "community score",
+			order:       OrderScore,
+			communityID: 10,
+			page:        1,
+			size:        10,
+			wantIDs:     []int64{1, 3},
+		},
+		{
+			name:        "empty community",
+			order:       OrderScore,
+			communityID: 30,
+			page:        1,
+			size:        10,
+			wantIDs:     []int64{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, err := GetPostIDsInOrder(ctx, tc.order, tc.communityID, tc.page, tc.size)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
+
+func TestRemovePost(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, CreatePost(ctx, 1, 10, now))
+	require.NoError(t, CreatePost(ctx, 2, 10, now))
+	_, err := VoteForPost(ctx, 100, 1, 1, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, RemovePost(ctx, 1, 10))
+	ids, err := GetPostIDsInOrder(ctx, OrderScore, 10, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids)
+	_, err = VoteForPost(ctx, 101, 1, 1, 0)
+	assert.Equal(t, code.ErrorPostNotExist, err)
+
+	// 重新发布之后分数按照之前的投票计算
+	require.NoError(t, CreatePost(ctx, 1, 10, now))
+	data, err := GetPostVoteData(ctx, []int64{1})
+	require.NoError(t, err)
+	assert.Equal(t, []PostVoteData{{Up: 1, Score: PostScore(1, 0, now)}}, data)
+	ids, err = GetPostIDsInOrder(ctx, OrderTime, 0, 1, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, ids)
+}
+
+func TestBackfillPosts(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, CreatePost(ctx, 1, 10, now))
+	_, err := VoteForPost(ctx, 100, 2, 1, 0)
+	assert.Equal(t, code.ErrorPostNotExist, err)
+
+	n, err := BackfillPosts(ctx, []PostMeta{
+		// 已经在列表里的帖子不修改发帖时间
+		{ID: 1, CommunityID: 10, CreateTime: now.Add(-time.Hour)},
+		{ID: 2, CommunityID: 20, CreateTime: now.Add(-2 * time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	createUnix, err := rdb.ZScore(ctx, getRedisKey(keyPostTimeZSet), "1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, float64(now.Unix()), createUnix)
+	ids, err := GetPostIDsInOrder(ctx, OrderTime, 20, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids)
+
+	// 补进去之后就可以投票了
+	changed, err := VoteForPost(ctx, 100, 2, 1, 0)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// 重复执行没有影响
+	n, err = BackfillPosts(ctx, []PostMeta{{ID: 2, CommunityID: 20, CreateTime: now}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// scoreEpoch 计算分数的起始时间，沿用 reddit 的 2005-12-08 07:46:43 UTC
+	scoreEpoch int64 = 1134028003
+	// scoreDecay 每过 45000 秒（12.5 小时），新帖子需要多 10 倍的净票数才能追平旧帖子
+	scoreDecay = 45000
+)
+
+// PostScore 参考 reddit 的 hot 算法计算帖子的分数
+// 净票数取对数，发帖时间越晚分数越高，所以分数只需要在投票的时候重新计算，
+// 时间带来的衰减体现在新帖子天然比旧帖子的分数高
+func PostScore(up, down int64, createTime time.Time) float64 {
+	diff := up - down
+	order := math.Log10(math.Max(math.Abs(float64(diff)), 1))
+	var sign float64
+	switch {
+	case diff > 0:
+		sign = 1
+	case diff < 0:
+		sign = -1
+	}
+	seconds := float64(createTime.Unix() - scoreEpoch)
+	score := sign*order + seconds/scoreDecay
+	// 保留 7 位小数，避免浮点误差导致同样的票数算出不同的分数
+	return math.Round(score*1e7) / 1e7
+}
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostScore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	base := PostScore(0, 0, now)
+
+	testCases := []struct {
+		name       string
+		up         int64
+		down       int64
+		createTime time.Time
+		wantScore  float64
+	}{
+		{
+			name:       "no vote",
+			createTime: now,
+			wantScore:  base,
+		},
+		{
+			name:       "one vote is the same as no vote",
+			up:         1,
+			createTime: now,
+			wantScore:  base,
+		},
+		{
+			name:       "ten up votes",
+			up:         10,
+			createTime: now,
+			wantScore:  base + 1,
+		},
+		{
+			name:       "ten down votes",
+			up:         5,
+			down:       15,
+			createTime: now,
+			wantScore:  base - 1,
+		},
+		{
+			name:       "decay",
+			up:         10,
+			createTime: now.Add(-scoreDecay * time.Second),
+			wantScore:  base,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.wantScore, PostScore(tc.up, tc.down, tc.createTime), 1e-6)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// GetUserProfile 从缓存里查询用户资料，第二个返回值为 false 表示没有缓存
+func GetUserProfile(ctx context.Context, userID int64) ([]byte, bool, error) {
+	data, err := rdb.Get(ctx, getUserProfileKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// SetUserProfile 缓存用户资料
+func SetUserProfile(ctx context.Context, userID int64, data []byte, expiration time.Duration) error {
+	return rdb.Set(ctx, getUserProfileKey(userID), data, expiration).Err()
+}
+
+// DelUserProfile 删除用户资料的缓存，修改资料之后调用
+func DelUserProfile(ctx context.Context, userID int64) error {
+	return rdb.Del(ctx, getUserProfileKey(userID)).Err()
+}
+
+// GetTokenVersion 从缓存里查询用户当前的 token 版本，第二个返回值为 false 表示没有缓存
+func GetTokenVersion(ctx context.Context, userID int64) (int64, bool, error) {
+	v, err := rdb.Get(ctx, getUserTokenVersionKey(userID)).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return v, true, nil
+}
+
+// SetTokenVersion 缓存用户当前的 token 版本
+func SetTokenVersion(ctx context.Context, userID, version int64, expiration time.Duration) error {
+	return rdb.Set(ctx, getUserTokenVersionKey(userID), version, expiration).Err()
+}
+
+// DelTokenVersion 删除 token 版本的缓存，修改密码和注销之后调用
+func DelTokenVersion(ctx context.Context, userID int64) error {
+	return rdb.Del(ctx, getUserTokenVersionKey(userID)).Err()
+}
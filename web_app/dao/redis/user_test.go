@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserProfile(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+
+	_, ok, err := GetUserProfile(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, SetUserProfile(ctx, 100, []byte(`{"user_id":100}`), time.Minute))
+	data, ok, err := GetUserProfile(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `{"user_id":100}`, string(data))
+
+	require.NoError(t, DelUserProfile(ctx, 100))
+	_, ok, err = GetUserProfile(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, SetUserProfile(ctx, 100, []byte(`{}`), time.Minute))
+	mr.FastForward(2 * time.Minute)
+	_, ok, err = GetUserProfile(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTokenVersion(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+
+	_, ok, err := GetTokenVersion(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 0 也要能缓存
+	require.NoError(t, SetTokenVersion(ctx, 100, 0, time.Minute))
+	v, ok, err := GetTokenVersion(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), v)
+
+	require.NoError(t, SetTokenVersion(ctx, 100, 3, time.Minute))
+	v, ok, err = GetTokenVersion(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), v)
+
+	require.NoError(t, DelTokenVersion(ctx, 100))
+	_, ok, err = GetTokenVersion(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, SetTokenVersion(ctx, 100, 1, time.Minute))
+	mr.FastForward(2 * time.Minute)
+	_, ok, err = GetTokenVersion(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
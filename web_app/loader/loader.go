@@ -0,0 +1,76 @@
+package loader
+
+import (
+	"bookstore/web_app/community"
+	"bookstore/web_app/pkg/dataloader"
+	"bookstore/web_app/user"
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ctxLoadersKey struct{}
+
+// loaders 一个请求里面用到的所有 loader
+type loaders struct {
+	users       *dataloader.Loader[int64, *user.User]
+	communities *dataloader.Loader[int64, community.DBCommunity]
+}
+
+func newLoaders() *loaders {
+	return &loaders{
+		users: dataloader.New(func(ctx context.Context, ids []int64) (map[int64]*user.User, error) {
+			users, err := user.GetUsersByIDs(ids)
+			if err != nil {
+				return nil, err
+			}
+			res := make(map[int64]*user.User, len(users))
+			for _, u := range users {
+				res[u.UserID] = u
+			}
+			return res, nil
+		}),
+		communities: dataloader.New(func(ctx context.Context, ids []int64) (map[int64]community.DBCommunity, error) {
+			communities, err := community.GetCommunitiesByIDs(ids)
+			if err != nil {
+				return nil, err
+			}
+			res := make(map[int64]community.DBCommunity, len(communities))
+			for _, c := range communities {
+				res[c.CommunityID] = c
+			}
+			return res, nil
+		}),
+	}
+}
+
+// Middleware 给每个请求创建一份 loader，同一个请求里对用户和社区的查询会被合并和缓存
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(WithLoaders(ctx.Request.Context()))
+		ctx.Next()
+	}
+}
+
+// WithLoaders 创建一份新的 loader 放到 ctx 里面，不在 HTTP 请求里的时候使用
+func WithLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxLoadersKey{}, newLoaders())
+}
+
+// from 没有使用 Middleware 的时候每次都创建新的 loader，仍然会合并同一次调用的查询
+func from(ctx context.Context) *loaders {
+	if l, ok := ctx.Value(ctxLoadersKey{}).(*loaders); ok {
+		return l
+	}
+	return newLoaders()
+}
+
+// Users 批量查询用户，返回 user_id 到用户的映射，不存在的用户不在结果里
+func Users(ctx context.Context, ids []int64) (map[int64]*user.User, error) {
+	return from(ctx).users.LoadMany(ctx, ids)
+}
+
+// Communities 批量查询社区，返回 community_id 到社区的映射，不存在的社区不在结果里
+func Communities(ctx context.Context, ids []int64) (map[int64]community.DBCommunity, error) {
+	return from(ctx).communities.LoadMany(ctx, ids)
+}
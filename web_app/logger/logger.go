@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bookstore/web_app/conf"
+	"context"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -75,6 +76,31 @@ func Init(cfg *conf.LogConfig, mode string) error {
 	return nil
 }
 
+type requestIDKey struct{}
+
+// ContextWithRequestID 把请求 ID 放进 context
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 读取请求 ID，没有就返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if gc, ok := ctx.(*gin.Context); ok {
+		ctx = gc.Request.Context()
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Ctx 返回带上请求 ID 的 logger，业务代码打日志的时候用这个代替 zap.L()
+// 可以直接传 *gin.Context
+func Ctx(ctx context.Context) *zap.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return zap.L().With(zap.String("request_id", id))
+	}
+	return zap.L()
+}
+
 // GinLogger 接收 gin 框架默认的日志
 func GinLogger() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -84,7 +110,7 @@ func GinLogger() gin.HandlerFunc {
 		ctx.Next()
 
 		cost := time.Since(start)
-		zap.L().Info(path,
+		Ctx(ctx).Info(path,
 			zap.Int("status", ctx.Writer.Status()),
 			zap.String("method", ctx.Request.Method),
 			zap.String("path", path),
@@ -116,7 +142,7 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 
 				httpRequest, _ := httputil.DumpRequest(ctx.Request, false)
 				if brokenPipe {
-					zap.L().Error(ctx.Request.URL.Path,
+					Ctx(ctx).Error(ctx.Request.URL.Path,
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 					)
@@ -127,13 +153,13 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 				}
 
 				if stack {
-					zap.L().Error("[Recovery from panic]",
+					Ctx(ctx).Error("[Recovery from panic]",
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 						zap.String("stack", string(debug.Stack())),
 					)
 				} else {
-					zap.L().Error("[Recovery from panic]",
+					Ctx(ctx).Error("[Recovery from panic]",
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 					)
@@ -1,11 +1,16 @@
 package middlewares
 
 import (
+	"bookstore/web_app/code"
 	"bookstore/web_app/controller"
+	"bookstore/web_app/logger"
 	"bookstore/web_app/pkg/jwt"
+	"bookstore/web_app/user"
+	"errors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const CtxUserIDKey = "userID"
@@ -40,8 +45,21 @@ func JWTAuthMiddleware() func(ctx *gin.Context) {
 			ctx.Abort()
 			return
 		}
+		// 修改密码或者注销之后，之前签发的 token 不能再用
+		err = user.CheckTokenVersion(ctx.Request.Context(), mc.UserID, mc.TokenVersion)
+		if errors.Is(err, code.ErrorTokenRevoked) {
+			controller.ResponseError(ctx, controller.CodeInvalidToken)
+			ctx.Abort()
+			return
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error("user.CheckTokenVersion failed", zap.Int64("user_id", mc.UserID), zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			ctx.Abort()
+			return
+		}
 		// 将当前请求的 userID 信息保存到请求的上下文 ctx 上
-		ctx.Set(CtxUserIDKey, mc)
+		ctx.Set(CtxUserIDKey, mc.UserID)
 		// 后续的处理函数可以通过 ctx.Get(CtxUserIDKey) 来获取当前请求的用户信息
 		ctx.Next()
 	}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"bookstore/web_app/logger"
+	"bookstore/web_app/snowflake"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	CtxRequestIDKey    = "requestID"
+	maxRequestIDLength = 128
+)
+
+// RequestIDMiddleware 为每个请求分配一个 ID
+// 请求头里面带了合法的 ID 就沿用（一般是网关生成的），否则用 snowflake 生成一个新的。
+// 需要放在 GinLogger 前面，这样访问日志和业务日志（logger.Ctx）都会带上 request_id
+func RequestIDMiddleware() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = strconv.FormatInt(snowflake.GenID(), 10)
+		}
+
+		ctx.Header(RequestIDHeader, id)
+		ctx.Set(CtxRequestIDKey, id)
+		reqCtx := logger.ContextWithRequestID(ctx.Request.Context(), id)
+		// 接入了链路追踪的话，顺便记到 span 上
+		trace.SpanFromContext(reqCtx).SetAttributes(attribute.String("http.request_id", id))
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
+
+// validRequestID 只接受可见的 ASCII 字符，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
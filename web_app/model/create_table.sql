@@ -2,9 +2,14 @@ CREATE TABLE IF NOT EXISTS `user` (
     `id` bigint(20) NOT NULL AUTO_INCREMENT,
     `user_id` bigint(20) NOT NULL UNIQUE COMMENT '用户编号',
     `username` varchar(64) NOT NULL UNIQUE COMMENT '用户名',
-    `password` varchar(64) NOT NULL COMMENT '密码',
+    `password` varchar(255) NOT NULL COMMENT '加密之后的密码，带着算法和参数',
     `email` varchar(64) NOT NULL UNIQUE COMMENT '邮箱',
     `gender` tinyint(4) NOT NULL DEFAULT '0',
+    `role` tinyint(4) NOT NULL DEFAULT '0' COMMENT '0 普通用户 1 管理员',
+    `avatar` varchar(256) NOT NULL DEFAULT '' COMMENT '头像地址',
+    `bio` varchar(256) NOT NULL DEFAULT '' COMMENT '个人简介',
+    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '0 已注销 1 正常',
+    `token_version` int(11) NOT NULL DEFAULT '0' COMMENT '修改密码和注销的时候加一，之前签发的 token 失效',
     `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
     `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`)
@@ -15,20 +20,22 @@ use sql_demo;
 DROP TABLE if exists `community`;
 CREATE TABLE `community` (
     `id` int(11) NOT NULL AUTO_INCREMENT,
-    `community_id` int(10) unsigned NOT NULL,
+    `community_id` bigint(20) NOT NULL,
     `community_name` varchar(128) COLLATE utf8mb4_general_ci NOT NULL,
     `introduction` varchar(256) COLLATE utf8mb4_general_ci NOT NULL,
+    `private` tinyint(4) NOT NULL DEFAULT '0' COMMENT '私有社区只有成员可以发帖',
     `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
     UNIQUE KEY `community_id_idx` (`community_id`),
-    UNIQUE KEY  `community_name_idx` (`community_name`)
+    UNIQUE KEY  `community_name_idx` (`community_name`),
+    FULLTEXT KEY `community_name_introduction_ft` (`community_name`, `introduction`) WITH PARSER ngram
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
 
-INSERT INTO `community` VALUES ('1', '1', 'Go', 'Golang', '2016-11-01 08:10:10', '2016-11-01 08:10:10');
-INSERT INTO `community` VALUES ('2', '2', 'leetcode', '刷题刷题刷题', '2020-01-01 08:00:00', '2020-01-01 08:00:00');
-INSERT INTO `community` VALUES ('3', '3', 'CS:GO', 'Rush B...', '2018-08-07 08:30:00', '2018-08-07 08:30:00');
-INSERT INTO `community` VALUES ('4', '4', 'LOL', '欢迎来到英雄联盟', '2016-01-01 08:00:00', '2016-01-01 08:00:00');
+INSERT INTO `community` VALUES ('1', '1', 'Go', 'Golang', '0', '2016-11-01 08:10:10', '2016-11-01 08:10:10');
+INSERT INTO `community` VALUES ('2', '2', 'leetcode', '刷题刷题刷题', '0', '2020-01-01 08:00:00', '2020-01-01 08:00:00');
+INSERT INTO `community` VALUES ('3', '3', 'CS:GO', 'Rush B...', '0', '2018-08-07 08:30:00', '2018-08-07 08:30:00');
+INSERT INTO `community` VALUES ('4', '4', 'LOL', '欢迎来到英雄联盟', '0', '2016-01-01 08:00:00', '2016-01-01 08:00:00');
 
 DROP TABLE IF EXISTS `post`;
 
@@ -39,11 +46,81 @@ CREATE TABLE `post` (
     `content` varchar(8192) COLLATE utf8mb4_general_ci NOT NULL COMMENT '内容',
     `author_id` bigint(20) NOT NULL COMMENT '作者的用户id',
     `community_id` bigint(20) NOT NULL COMMENT '所属社区',
-    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '帖子状态',
-    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '帖子状态 0 草稿 1 已发布 2 已隐藏 3 已删除',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '发帖时间，草稿发布的时候改成发布时间',
     `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     primary key (`id`),
     UNIQUE KEY `post_id_idx` (`post_id`),
     KEY `author_id_idx` (`author_id`),
-    KEY `community_id_idx` (`community_id`)
-) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
\ No newline at end of file
+    KEY `community_id_idx` (`community_id`),
+    FULLTEXT KEY `title_content_ft` (`title`, `content`) WITH PARSER ngram
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `post_vote`;
+
+CREATE TABLE `post_vote` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `user_id` bigint(20) NOT NULL COMMENT '投票的用户id',
+    `direction` tinyint(4) NOT NULL COMMENT '1 赞成 -1 反对 0 取消投票',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '投票时间',
+    PRIMARY KEY (`id`),
+    KEY `post_id_user_id_idx` (`post_id`, `user_id`)
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT '投票记录，投票状态以 redis 为准';
+
+
+DROP TABLE IF EXISTS `post_history`;
+
+CREATE TABLE `post_history` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `editor_id` bigint(20) NOT NULL COMMENT '修改帖子的用户id',
+    `title` varchar(128) COLLATE utf8mb4_general_ci NOT NULL COMMENT '修改之前的标题',
+    `content` varchar(8192) COLLATE utf8mb4_general_ci NOT NULL COMMENT '修改之前的内容',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '修改时间',
+    PRIMARY KEY (`id`),
+    KEY `post_id_idx` (`post_id`)
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT '帖子的修改记录';
+
+
+DROP TABLE IF EXISTS `comment`;
+
+CREATE TABLE `comment` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `comment_id` bigint(20) NOT NULL COMMENT '评论id',
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `author_id` bigint(20) NOT NULL COMMENT '评论者的用户id',
+    `parent_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '回复的评论id，0 表示直接评论帖子',
+    `root_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '所在楼层的第一条评论id，0 表示自己就是第一条',
+    `content` varchar(2048) COLLATE utf8mb4_general_ci NOT NULL COMMENT '内容',
+    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '评论状态 0 已删除 1 正常',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `comment_id_idx` (`comment_id`),
+    KEY `post_id_root_id_idx` (`post_id`, `root_id`, `comment_id`)
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+
+DROP TABLE IF EXISTS `community_member`;
+
+CREATE TABLE `community_member` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `community_id` bigint(20) NOT NULL COMMENT '社区id',
+    `user_id` bigint(20) NOT NULL COMMENT '成员的用户id',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '加入时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `community_id_user_id_idx` (`community_id`, `user_id`),
+    KEY `user_id_idx` (`user_id`)
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT '社区成员';
+
+DROP TABLE IF EXISTS `community_moderator`;
+
+CREATE TABLE `community_moderator` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `community_id` bigint(20) NOT NULL COMMENT '社区id',
+    `user_id` bigint(20) NOT NULL COMMENT '版主的用户id',
+    `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `community_id_user_id_idx` (`community_id`, `user_id`)
+) ENGINE=Innodb DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci COMMENT '社区版主';
@@ -0,0 +1,71 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("cursor: invalid cursor")
+
+// Cursor 游标分页的位置，记录上一页最后一条数据的时间和 id
+// 对客户端来说是不透明的，客户端只需要把 next_cursor 原样带回来
+// 只按照 id 翻页的列表（比如社区列表）Time 为零值
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// IsZero 是否是第一页
+func (c Cursor) IsZero() bool {
+	return c.ID == 0 && c.Time.IsZero()
+}
+
+// Encode 编码成 URL 安全的字符串
+func (c Cursor) Encode() string {
+	var nano int64
+	if !c.Time.IsZero() {
+		nano = c.Time.UnixNano()
+	}
+	raw := strconv.FormatInt(nano, 10) + "," + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode 解析 Encode 得到的字符串，空字符串表示第一页
+func Decode(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	nanoStr, idStr, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	nano, err := strconv.ParseInt(nanoStr, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if nano != 0 {
+		c.Time = time.Unix(0, nano)
+	}
+	c.ID = id
+	return c, nil
+}
+
+// Page 游标分页的响应数据
+type Page struct {
+	List any `json:"list"`
+	// NextCursor 下一页的游标，没有下一页的时候为空
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
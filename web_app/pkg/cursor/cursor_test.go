@@ -0,0 +1,78 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{
+			name:   "time and id",
+			cursor: Cursor{Time: time.Unix(1700000000, 123), ID: 1234567890123},
+		},
+		{
+			name:   "id only",
+			cursor: Cursor{ID: 4},
+		},
+		{
+			name: "zero",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Decode(tc.cursor.Encode())
+			require.NoError(t, err)
+			assert.True(t, tc.cursor.Time.Equal(c.Time))
+			assert.Equal(t, tc.cursor.ID, c.ID)
+			assert.Equal(t, tc.cursor.IsZero(), c.IsZero())
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		s       string
+		wantErr error
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "not base64",
+			s:       "!!!",
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "no separator",
+			s:       base64.RawURLEncoding.EncodeToString([]byte("123")),
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "invalid id",
+			s:       base64.RawURLEncoding.EncodeToString([]byte("123,abc")),
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "negative id",
+			s:       base64.RawURLEncoding.EncodeToString([]byte("123,-1")),
+			wantErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode(tc.s)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
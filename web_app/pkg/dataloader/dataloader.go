@@ -0,0 +1,100 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchFunc 批量查询 keys 对应的数据，查不到的 key 不需要出现在结果里
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader 合并和缓存查询，一般在一个请求里面使用，避免 N+1 查询
+// 查不到的 key 也会被缓存下来，不会重复查询
+// 查询出错的时候不会缓存，下一次还会重新查询
+type Loader[K comparable, V any] struct {
+	fetch BatchFunc[K, V]
+
+	mu    sync.Mutex
+	cache map[K]V
+	// missing 查过但是不存在的 key
+	missing map[K]struct{}
+}
+
+func New[K comparable, V any](fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:   fetch,
+		cache:   make(map[K]V),
+		missing: make(map[K]struct{}),
+	}
+}
+
+// LoadMany 查询 keys 对应的数据，keys 里重复的和已经查过的 key 不会再查询
+// 只会调用一次 BatchFunc，返回的结果里没有查不到的 key
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var toFetch []K
+	seen := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, ok := l.cache[key]; ok {
+			continue
+		}
+		if _, ok := l.missing[key]; ok {
+			continue
+		}
+		toFetch = append(toFetch, key)
+	}
+
+	if len(toFetch) > 0 {
+		vals, err := l.fetch(ctx, toFetch)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range toFetch {
+			if val, ok := vals[key]; ok {
+				l.cache[key] = val
+			} else {
+				l.missing[key] = struct{}{}
+			}
+		}
+	}
+
+	res := make(map[K]V, len(seen))
+	for key := range seen {
+		if val, ok := l.cache[key]; ok {
+			res[key] = val
+		}
+	}
+	return res, nil
+}
+
+// Load 查询单个 key，ok 为 false 表示数据不存在
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (val V, ok bool, err error) {
+	res, err := l.LoadMany(ctx, []K{key})
+	if err != nil {
+		return val, false, err
+	}
+	val, ok = res[key]
+	return val, ok, nil
+}
+
+// Prime 把已经拿到的数据放进缓存，后面就不用再查了
+func (l *Loader[K, V]) Prime(key K, val V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache[key] = val
+	delete(l.missing, key)
+}
+
+// Clear 删掉 key 的缓存，数据被修改之后需要调用
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, key)
+	delete(l.missing, key)
+}
@@ -0,0 +1,73 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	var calls [][]int64
+	var fetchErr error
+	l := New(func(ctx context.Context, keys []int64) (map[int64]string, error) {
+		calls = append(calls, keys)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		res := make(map[int64]string, len(keys))
+		for _, key := range keys {
+			// 负数当成不存在的数据
+			if key > 0 {
+				res[key] = "v" + string(rune('0'+key))
+			}
+		}
+		return res, nil
+	})
+
+	// 重复的 key 只查询一次
+	res, err := l.LoadMany(ctx, []int64{1, 2, 1, -1})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{1: "v1", 2: "v2"}, res)
+	assert.Equal(t, [][]int64{{1, 2, -1}}, calls)
+
+	// 查过的 key，包括不存在的，不会再查询
+	res, err = l.LoadMany(ctx, []int64{2, 3, -1})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{2: "v2", 3: "v3"}, res)
+	assert.Equal(t, [][]int64{{1, 2, -1}, {3}}, calls)
+
+	// 都查过了，不会调用 BatchFunc
+	val, ok, err := l.Load(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v1", val)
+	_, ok, err = l.Load(ctx, -1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Len(t, calls, 2)
+
+	// 出错不缓存
+	fetchErr = errors.New("db error")
+	_, err = l.LoadMany(ctx, []int64{4})
+	assert.Equal(t, fetchErr, err)
+	fetchErr = nil
+	res, err = l.LoadMany(ctx, []int64{4})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{4: "v4"}, res)
+	assert.Equal(t, [][]int64{{1, 2, -1}, {3}, {4}, {4}}, calls)
+
+	// Prime 和 Clear
+	l.Prime(-1, "primed")
+	val, ok, err = l.Load(ctx, -1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "primed", val)
+	l.Clear(1)
+	_, _, err = l.Load(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, calls[len(calls)-1])
+}
@@ -20,15 +20,18 @@ var MySecret = []byte("夏天夏天悄悄过去")
 type MyClaims struct {
 	UserID   int64  `json:"user_id"`
 	UserName string `json:"user_name"`
+	// TokenVersion 签发时用户的 token 版本，修改密码或者注销之后版本会变，之前的 token 就失效了
+	TokenVersion int64 `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
 // GenToken 生成 JWT
-func GenToken(userID int64, username string) (string, error) {
+func GenToken(userID int64, username string, tokenVersion int64) (string, error) {
 	// 创建一个我们自己的声明
 	claims := MyClaims{
-		UserID:   userID,
-		UserName: username,
+		UserID:       userID,
+		UserName:     username,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(viper.GetDuration(jwtExpKey))), // 过期时间
 			Issuer:    "bluebell",
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// argon2Hash 解析出来的 argon2id 密码
+// 格式是 $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>，salt 和 key 是没有填充的 base64
+type argon2Hash struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func hashArgon2id(password string, p Params) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(encoded string) (*argon2Hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("%w: 不支持的 argon2 版本 %d", ErrMalformedHash, version)
+	}
+
+	h := &argon2Hash{}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if h.time == 0 || h.threads == 0 || len(h.key) == 0 {
+		return nil, ErrMalformedHash
+	}
+	return h, nil
+}
+
+func (h *argon2Hash) verify(password string) bool {
+	key := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	return subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+func (h *argon2Hash) sameParams(p Params) bool {
+	return h.time == p.Argon2Time && h.memory == p.Argon2Memory && h.threads == p.Argon2Threads &&
+		len(h.salt) == argon2SaltLen && len(h.key) == argon2KeyLen
+}
@@ -0,0 +1,23 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// legacySecret 以前 util.EncryptPassword 使用的盐
+const legacySecret = "wsqigo"
+
+// legacyHash 以前的 MD5 加密，结果是密码本身拼上 md5(secret) 的十六进制，
+// 只用来验证还没有迁移的旧密码，不要再用它加密
+func legacyHash(password string) string {
+	h := md5.New()
+	h.Write([]byte(legacySecret))
+
+	return hex.EncodeToString(h.Sum([]byte(password)))
+}
+
+func verifyLegacy(password, encoded string) bool {
+	return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1
+}
@@ -0,0 +1,152 @@
+package password
+
+import (
+	"bookstore/web_app/conf"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 加密密码的算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrMalformedHash 数据库里的密码格式不对
+var ErrMalformedHash = errors.New("password: malformed hash")
+
+// Params 加密的参数，Argon2Memory 的单位是 KiB
+type Params struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// DefaultParams 没有配置的时候使用的参数，argon2id 使用 RFC 9106 推荐的第二组参数
+var DefaultParams = Params{
+	Algorithm:     AlgorithmArgon2id,
+	BcryptCost:    12,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 2,
+}
+
+// params 全局使用的参数，通过 Init 设置
+var params = DefaultParams
+
+// limiter 限制同时加密和验证的数量，每个 argon2id 要占用 Argon2Memory 的内存，
+// 不限制的话大量并发的登录请求就能把内存耗尽，默认和 CPU 数量一样
+var limiter = make(chan struct{}, runtime.NumCPU())
+
+// Init 根据配置设置加密的参数，没有配置的字段使用默认值
+func Init(cfg *conf.PasswordConfig) error {
+	p := DefaultParams
+	if cfg != nil {
+		if cfg.Algorithm != "" {
+			p.Algorithm = cfg.Algorithm
+		}
+		if cfg.BcryptCost != 0 {
+			p.BcryptCost = cfg.BcryptCost
+		}
+		if cfg.Argon2Time != 0 {
+			p.Argon2Time = cfg.Argon2Time
+		}
+		if cfg.Argon2Memory != 0 {
+			p.Argon2Memory = cfg.Argon2Memory
+		}
+		if cfg.Argon2Threads != 0 {
+			p.Argon2Threads = cfg.Argon2Threads
+		}
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+	params = p
+	if cfg != nil && cfg.MaxConcurrency > 0 {
+		limiter = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	return nil
+}
+
+func (p Params) validate() error {
+	switch p.Algorithm {
+	case AlgorithmArgon2id:
+		if p.Argon2Time == 0 || p.Argon2Memory < 8*uint32(p.Argon2Threads) || p.Argon2Threads == 0 {
+			return fmt.Errorf("password: argon2id 参数不合法 t=%d m=%d p=%d", p.Argon2Time, p.Argon2Memory, p.Argon2Threads)
+		}
+	case AlgorithmBcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("password: bcrypt cost %d 不在 [%d, %d] 之间", p.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("password: 不支持的算法 %s", p.Algorithm)
+	}
+	return nil
+}
+
+// Hash 使用当前配置的算法加密密码，结果里带着算法和参数，可以直接存数据库
+func Hash(password string) (string, error) {
+	l := limiter
+	l <- struct{}{}
+	defer func() { <-l }()
+	return params.Hash(password)
+}
+
+// Verify 验证密码，needRehash 为 true 表示密码正确，但是应该用 Hash 重新加密之后保存，
+// 比如旧的 MD5 密码，或者修改了算法和参数
+func Verify(password, encoded string) (ok, needRehash bool, err error) {
+	l := limiter
+	l <- struct{}{}
+	defer func() { <-l }()
+	return params.Verify(password, encoded)
+}
+
+// Hash 使用 p 的算法和参数加密密码
+func (p Params) Hash(password string) (string, error) {
+	if p.Algorithm == AlgorithmBcrypt {
+		// bcrypt 只使用前 72 个字节
+		res, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(res), err
+	}
+	return hashArgon2id(password, p)
+}
+
+// Verify 验证密码，并且判断 encoded 是不是用 p 加密的
+func (p Params) Verify(password, encoded string) (ok, needRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		h, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		if !h.verify(password) {
+			return false, false, nil
+		}
+		return true, p.Algorithm != AlgorithmArgon2id || !h.sameParams(p), nil
+	case strings.HasPrefix(encoded, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return true, p.Algorithm != AlgorithmBcrypt || cost != p.BcryptCost, nil
+	case strings.HasPrefix(encoded, "$"):
+		return false, false, ErrMalformedHash
+	default:
+		// 没有前缀的是以前的 MD5 密码，验证通过之后总是要重新加密
+		ok := verifyLegacy(password, encoded)
+		return ok, ok, nil
+	}
+}
@@ -0,0 +1,174 @@
+package password
+
+import (
+	"bookstore/web_app/conf"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试里用最小的参数，不然太慢
+var (
+	fastArgon2 = Params{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.MinCost, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+	fastBcrypt = Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+)
+
+func TestParams_HashAndVerify(t *testing.T) {
+	testCases := []struct {
+		name       string
+		params     Params
+		wantPrefix string
+	}{
+		{name: "argon2id", params: fastArgon2, wantPrefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{name: "bcrypt", params: fastBcrypt, wantPrefix: "$2a$04$"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := tc.params.Hash("123456")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(encoded, tc.wantPrefix), encoded)
+			assert.NotContains(t, encoded, "123456")
+
+			// 每次的盐不一样
+			again, err := tc.params.Hash("123456")
+			require.NoError(t, err)
+			assert.NotEqual(t, encoded, again)
+
+			ok, needRehash, err := tc.params.Verify("123456", encoded)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.False(t, needRehash)
+
+			ok, needRehash, err = tc.params.Verify("1234567", encoded)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.False(t, needRehash)
+		})
+	}
+}
+
+func TestParams_VerifyNeedRehash(t *testing.T) {
+	argon2Hash, err := fastArgon2.Hash("123456")
+	require.NoError(t, err)
+	bcryptHash, err := fastBcrypt.Hash("123456")
+	require.NoError(t, err)
+
+	stronger := fastArgon2
+	stronger.Argon2Time = 2
+	strongerBcrypt := fastBcrypt
+	strongerBcrypt.BcryptCost = bcrypt.MinCost + 1
+
+	testCases := []struct {
+		name           string
+		params         Params
+		encoded        string
+		wantOK         bool
+		wantNeedRehash bool
+	}{
+		{name: "legacy md5", params: fastArgon2, encoded: legacyHash("123456"), wantOK: true, wantNeedRehash: true},
+		{name: "legacy md5 wrong password", params: fastArgon2, encoded: legacyHash("123456") + "00"},
+		{name: "empty hash", params: fastArgon2, encoded: ""},
+		{name: "argon2id params changed", params: stronger, encoded: argon2Hash, wantOK: true, wantNeedRehash: true},
+		{name: "argon2id to bcrypt", params: fastBcrypt, encoded: argon2Hash, wantOK: true, wantNeedRehash: true},
+		{name: "bcrypt cost changed", params: strongerBcrypt, encoded: bcryptHash, wantOK: true, wantNeedRehash: true},
+		{name: "bcrypt to argon2id", params: fastArgon2, encoded: bcryptHash, wantOK: true, wantNeedRehash: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, needRehash, err := tc.params.Verify("123456", tc.encoded)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantNeedRehash, needRehash)
+		})
+	}
+}
+
+func TestParams_VerifyMalformed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "unknown algorithm", encoded: "$scrypt$abc"},
+		{name: "missing parts", encoded: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+		{name: "bad version", encoded: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5"},
+		{name: "bad params", encoded: "$argon2id$v=19$m=64$c2FsdA$a2V5"},
+		{name: "bad salt", encoded: "$argon2id$v=19$m=64,t=1,p=1$!!$a2V5"},
+		{name: "zero time", encoded: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5"},
+		{name: "bad bcrypt", encoded: "$2a$04$short"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, _, err := fastArgon2.Verify("123456", tc.encoded)
+			assert.ErrorIs(t, err, ErrMalformedHash)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	defer func() { params = DefaultParams }()
+
+	testCases := []struct {
+		name    string
+		cfg     *conf.PasswordConfig
+		want    Params
+		wantErr bool
+	}{
+		{name: "nil", want: DefaultParams},
+		{
+			name: "bcrypt",
+			cfg:  &conf.PasswordConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 10},
+			want: Params{Algorithm: AlgorithmBcrypt, BcryptCost: 10, Argon2Time: 3, Argon2Memory: 64 * 1024, Argon2Threads: 2},
+		},
+		{name: "unknown algorithm", cfg: &conf.PasswordConfig{Algorithm: "md5"}, wantErr: true},
+		{name: "bcrypt cost too high", cfg: &conf.PasswordConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 40}, wantErr: true},
+		{name: "argon2 memory too low", cfg: &conf.PasswordConfig{Argon2Memory: 8, Argon2Threads: 4}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params = DefaultParams
+			err := Init(tc.cfg)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, DefaultParams, params)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, params)
+		})
+	}
+}
+
+func TestInit_MaxConcurrency(t *testing.T) {
+	defer func() {
+		params = DefaultParams
+		limiter = make(chan struct{}, runtime.NumCPU())
+	}()
+
+	require.NoError(t, Init(&conf.PasswordConfig{MaxConcurrency: 1}))
+	assert.Equal(t, 1, cap(limiter))
+
+	// 占满之后新的请求要等前面的结束
+	limiter <- struct{}{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = Verify("123456", legacyHash("123456"))
+	}()
+	select {
+	case <-done:
+		t.Fatal("Verify should wait for the limiter")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-limiter
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Verify should finish after the limiter is released")
+	}
+}
@@ -2,26 +2,162 @@ package post
 
 import (
 	"bookstore/web_app/dao/mysql"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/pkg/cursor"
+	"bookstore/web_app/search"
 	"bookstore/web_app/snowflake"
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 var db = mysql.GetDBConn()
 
-func GenAndInsertPost(post DBPost) error {
+func init() {
+	// MySQL 搜索和内存索引只包含已发布的帖子
+	search.SetPublishedPostStatus(StatusPublished)
+}
+
+func GenAndInsertPost(ctx context.Context, post DBPost) error {
 	// 1. 生成 post id
 	post.ID = snowflake.GenID()
+	// MySQL 和 redis 使用同一个发帖时间，timestamp 只精确到秒
+	post.CreateTime = time.Now().Truncate(time.Second)
 
 	// 2. 保存到数据库
-	sqlStr := `insert into post post_id, title, content, author_id, community_id
-    values (?, ?, ?, ?, ?)`
+	sqlStr := `insert into post(post_id, title, content, author_id, community_id, status, create_time)
+    values (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := db.Exec(sqlStr, post.ID, post.Title, post.Content, post.AuthorID, post.CommunityID, post.Status, post.CreateTime)
+	if err != nil {
+		return err
+	}
+
+	// 3. 记录发帖时间和初始分数，用于投票和排序，草稿发布的时候再记录
+	if post.Status != StatusPublished {
+		return nil
+	}
+	syncSearch(ctx, post)
+	return redis.CreatePost(ctx, post.ID, post.CommunityID, post.CreateTime)
+}
+
+// backfillBatchSize BackfillPostList 每次从 MySQL 查询的帖子数量
+const backfillBatchSize = 500
+
+// BackfillPostList 把已经发布、但是不在 redis 帖子列表里的帖子按照发帖时间补进去
+// 帖子列表上线之前发的帖子只在 MySQL 里，启动的时候调用，重复执行没有影响
+func BackfillPostList(ctx context.Context) error {
+	sqlStr := `select post_id, community_id, create_time from post
+    where status = ? and post_id > ?
+    order by post_id
+    limit ?`
 
-	_, err := db.Exec(sqlStr, post.ID, post.Title, post.Content, post.AuthorID)
-	return err
+	var lastID int64
+	total := 0
+	for {
+		posts := make([]DBPost, 0, backfillBatchSize)
+		if err := db.Select(&posts, sqlStr, StatusPublished, lastID, backfillBatchSize); err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			break
+		}
+		metas := make([]redis.PostMeta, 0, len(posts))
+		for _, p := range posts {
+			metas = append(metas, redis.PostMeta{ID: p.ID, CommunityID: p.CommunityID, CreateTime: p.CreateTime})
+		}
+		n, err := redis.BackfillPosts(ctx, metas)
+		total += n
+		if err != nil {
+			return err
+		}
+		lastID = posts[len(posts)-1].ID
+	}
+	zap.L().Info("backfill post list finished", zap.Int("count", total))
+	return nil
 }
 
-// GetPostByID 根据帖子 id 查询帖子详情数据
+// syncSearch 已发布的帖子更新搜索索引，其余状态的帖子从索引里删除
+func syncSearch(ctx context.Context, post DBPost) {
+	if post.Status != StatusPublished {
+		search.DeleteDocument(ctx, search.TypePost, post.ID)
+		return
+	}
+	search.IndexDocument(ctx, search.Document{
+		Type:    search.TypePost,
+		ID:      post.ID,
+		Title:   post.Title,
+		Content: post.Content,
+	})
+}
+
+// UpdatePost 修改帖子的标题、内容和状态，标题或者内容有变化的时候把修改之前的版本保存到 post_history
+// 帖子发布或者取消发布的时候同步更新 redis 里的帖子列表
+func UpdatePost(ctx context.Context, old, post DBPost, editorID int64) error {
+	// 草稿发布的时候把发帖时间改成发布的时间，MySQL 和 redis 使用同一个时间
+	// 隐藏之后重新发布的帖子还是按照原来的发帖时间
+	post.CreateTime = old.CreateTime
+	if old.Status == StatusDraft && post.Status == StatusPublished {
+		post.CreateTime = time.Now().Truncate(time.Second)
+	}
+	if err := updateDBPost(old, post, editorID); err != nil {
+		return err
+	}
+	syncSearch(ctx, post)
+
+	switch {
+	case old.Status != StatusPublished && post.Status == StatusPublished:
+		return redis.CreatePost(ctx, post.ID, post.CommunityID, post.CreateTime)
+	case old.Status == StatusPublished && post.Status != StatusPublished:
+		return redis.RemovePost(ctx, post.ID, post.CommunityID)
+	}
+	return nil
+}
+
+func updateDBPost(old, post DBPost, editorID int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if old.Title != post.Title || old.Content != post.Content {
+		sqlStr := `insert into post_history(post_id, editor_id, title, content) values (?, ?, ?, ?)`
+		if _, err = tx.Exec(sqlStr, old.ID, editorID, old.Title, old.Content); err != nil {
+			return err
+		}
+	}
+
+	sqlStr := `update post set title = ?, content = ?, status = ?, create_time = ? where post_id = ?`
+	if _, err = tx.Exec(sqlStr, post.Title, post.Content, post.Status, post.CreateTime, post.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePost 软删除帖子，同时从 redis 的帖子列表里移除
+func DeletePost(ctx context.Context, post DBPost) error {
+	sqlStr := `update post set status = ? where post_id = ?`
+
+	if _, err := db.Exec(sqlStr, StatusDeleted, post.ID); err != nil {
+		return err
+	}
+	search.DeleteDocument(ctx, search.TypePost, post.ID)
+	if post.Status != StatusPublished {
+		return nil
+	}
+	return redis.RemovePost(ctx, post.ID, post.CommunityID)
+}
+
+// GetPostByID 根据帖子 id 查询帖子详情数据，不区分帖子状态，调用方需要自己判断能不能看
 func GetPostByID(pid int64) (DBPost, error) {
-	sqlStr := `select post_id, title, content, author_id, community_id, create_time from post where post_id = ?`
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time from post where post_id = ?`
 
 	var post DBPost
 	err := db.Get(&post, sqlStr, pid)
@@ -32,10 +168,72 @@ func GetPostByID(pid int64) (DBPost, error) {
 	return post, nil
 }
 
+// GetDBPostList 按照发帖时间倒序分页查询已发布的帖子
 func GetDBPostList(page, size int64) ([]DBPost, error) {
-	sqlStr := `select post_id, title, content, author_id, community_id, create_time from post limit ?,?`
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time from post
+    where status = ?
+    order by create_time desc, post_id desc
+    limit ?,?`
+
+	posts := make([]DBPost, 0, size)
+	err := db.Select(&posts, sqlStr, StatusPublished, (page-1)*size, size)
+	return posts, err
+}
+
+// GetDBPostListByCursor 从游标的位置开始按照发帖时间倒序查询 size 条已发布的帖子
+// 同一秒发的帖子按照 post_id 倒序，保证翻页的时候不重复也不遗漏
+func GetDBPostListByCursor(c cursor.Cursor, size int64) ([]DBPost, error) {
+	posts := make([]DBPost, 0, size)
+	if c.IsZero() {
+		sqlStr := `select post_id, title, content, author_id, community_id, status, create_time from post
+    where status = ?
+    order by create_time desc, post_id desc
+    limit ?`
+		err := db.Select(&posts, sqlStr, StatusPublished, size)
+		return posts, err
+	}
+
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time from post
+    where status = ? and (create_time < ? or (create_time = ? and post_id < ?))
+    order by create_time desc, post_id desc
+    limit ?`
+	err := db.Select(&posts, sqlStr, StatusPublished, c.Time, c.Time, c.ID, size)
+	return posts, err
+}
+
+// GetPostListByIDs 根据给定的帖子 id 列表查询已发布的帖子，结果按照 ids 的顺序排列
+// 已经不存在或者没有发布的帖子会被跳过
+func GetPostListByIDs(ids []int64) ([]DBPost, error) {
+	if len(ids) == 0 {
+		return []DBPost{}, nil
+	}
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time from post
+    where post_id in (?) and status = ?
+    order by FIELD(post_id, ?)`
+
+	query, args, err := sqlx.In(sqlStr, ids, StatusPublished, ids)
+	if err != nil {
+		return nil, err
+	}
 
-	posts := make([]DBPost, 0, 2)
-	err := db.Get(&posts, sqlStr, (page-1)*size, size)
+	posts := make([]DBPost, 0, len(ids))
+	err = db.Select(&posts, db.Rebind(query), args...)
 	return posts, err
 }
+
+// commentCounter 批量查询帖子没有删除的评论数，由 comment 包注册
+// comment 包依赖 post 包，所以这里不能直接调用 comment 包
+var commentCounter func(ids []int64) (map[int64]int64, error)
+
+// SetCommentCounter 注册查询评论数的函数，返回 post_id 到评论数的映射
+func SetCommentCounter(fn func(ids []int64) (map[int64]int64, error)) {
+	commentCounter = fn
+}
+
+// getCommentCounts 批量查询帖子的评论数，没有注册 commentCounter 的时候都是 0
+func getCommentCounts(ids []int64) (map[int64]int64, error) {
+	if commentCounter == nil || len(ids) == 0 {
+		return make(map[int64]int64), nil
+	}
+	return commentCounter(ids)
+}
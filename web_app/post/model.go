@@ -4,6 +4,21 @@ import (
 	"time"
 )
 
+// 帖子状态
+const (
+	StatusDraft     int32 = iota // 草稿，只有作者能看到
+	StatusPublished              // 已发布
+	StatusHidden                 // 已隐藏，只有作者和版主能看到
+	StatusDeleted                // 已删除，软删除，谁都看不到
+)
+
+// statusNames 接口里使用的帖子状态的名字
+var statusNames = map[string]int32{
+	"draft":     StatusDraft,
+	"published": StatusPublished,
+	"hidden":    StatusHidden,
+}
+
 type DBPost struct {
 	ID          int64     `json:"id" db:"post_id"`
 	Title       string    `json:"title" db:"title"`
@@ -20,4 +35,10 @@ type ApiPostDetail struct {
 
 	AuthorName    string `json:"author_name"`
 	CommunityName string `json:"community_name"` // 嵌入社区信息
+
+	VoteUp   int64   `json:"vote_up"`   // 赞成票数
+	VoteDown int64   `json:"vote_down"` // 反对票数
+	Score    float64 `json:"score"`     // 按照投票和发帖时间计算的分数
+
+	CommentCount int64 `json:"comment_count"` // 没有删除的评论数
 }
@@ -1,9 +1,17 @@
 package post
 
 import (
+	"bookstore/web_app/code"
 	"bookstore/web_app/community"
 	"bookstore/web_app/controller"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/loader"
+	"bookstore/web_app/logger"
+	"bookstore/web_app/pkg/cursor"
 	"bookstore/web_app/user"
+	"context"
+	"database/sql"
+	"errors"
 	"strconv"
 
 	"go.uber.org/zap"
@@ -16,6 +24,8 @@ type postReq struct {
 	CommunityID int64  `json:"community_id" binding:"required"`
 	Title       string `json:"title" binding:"required"`
 	Content     string `json:"content" binding:"required"`
+	// Draft 为 true 的时候保存成草稿，草稿不会出现在帖子列表里
+	Draft bool `json:"draft"`
 }
 
 // CreatePostHandler 创建帖子
@@ -24,8 +34,8 @@ func CreatePostHandler(ctx *gin.Context) {
 	req := postReq{}
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		zap.L().Debug("ctx.ShouldBindJSON() err", zap.Any("err", err))
-		zap.L().Error("create post with invalid param")
+		logger.Ctx(ctx).Debug("ctx.ShouldBindJSON() err", zap.Any("err", err))
+		logger.Ctx(ctx).Error("create post with invalid param")
 		controller.ResponseError(ctx, controller.CodeInvalidParam)
 		return
 	}
@@ -38,16 +48,38 @@ func CreatePostHandler(ctx *gin.Context) {
 		return
 	}
 
+	// 私有社区只有成员可以发帖
+	ok, err := community.CanPost(req.CommunityID, userID)
+	if errors.Is(err, code.ErrorInvalidID) {
+		controller.ResponseError(ctx, controller.CodeCommunityNotExist)
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("community.CanPost failed",
+			zap.Int64("community_id", req.CommunityID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodeNotCommunityMember)
+		return
+	}
+
 	p := DBPost{
 		AuthorID:    userID,
 		CommunityID: req.CommunityID,
 		Title:       req.Title,
 		Content:     req.Content,
+		Status:      StatusPublished,
+	}
+	if req.Draft {
+		p.Status = StatusDraft
 	}
 
-	err = GenAndInsertPost(p)
+	err = GenAndInsertPost(ctx.Request.Context(), p)
 	if err != nil {
-		zap.L().Error("GenAndInsertPost failed", zap.Error(err))
+		logger.Ctx(ctx).Error("GenAndInsertPost failed", zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy)
 		return
 	}
@@ -61,25 +93,21 @@ func GetPostDetailHandler(ctx *gin.Context) {
 	pidStr := ctx.Param("id")
 	pid, err := strconv.ParseInt(pidStr, 10, 64)
 	if err != nil {
-		zap.L().Error("get post detail with invalid param",
+		logger.Ctx(ctx).Error("get post detail with invalid param",
 			zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeInvalidParam)
 		return
 	}
 
-	post, err := GetPostByID(pid)
-	if err != nil {
-		zap.L().Error("GetPostByID failed",
-			zap.Int64("pid", pid),
-			zap.Error(err))
-		controller.ResponseError(ctx, controller.CodeServerBusy)
+	post, ok := getVisiblePost(ctx, pid)
+	if !ok {
 		return
 	}
 
 	// 根据作者 id 查询作者信息
 	u, err := user.GetUserById(post.AuthorID)
 	if err != nil {
-		zap.L().Error("GetUserByID() failed",
+		logger.Ctx(ctx).Error("GetUserByID() failed",
 			zap.Int64("author_id", post.AuthorID),
 			zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy)
@@ -89,55 +117,302 @@ func GetPostDetailHandler(ctx *gin.Context) {
 	// 根据社区 id 查询社区详细信息
 	communityInfo, err := community.GetCommunityDetailByID(post.CommunityID)
 	if err != nil {
-		zap.L().Error("GetCommunityDetailByID() failed",
+		logger.Ctx(ctx).Error("GetCommunityDetailByID() failed",
 			zap.Int64("community_id", post.CommunityID),
 			zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy)
 		return
 	}
 
+	// 查询投票数据
+	voteData, err := redis.GetPostVoteData(ctx.Request.Context(), []int64{pid})
+	if err != nil {
+		logger.Ctx(ctx).Error("GetPostVoteData() failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	// 查询评论数
+	commentCounts, err := getCommentCounts([]int64{pid})
+	if err != nil {
+		logger.Ctx(ctx).Error("getCommentCounts() failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
 	// 接口数据拼接
 	data := ApiPostDetail{
 		AuthorName:    u.Username,
 		DBPost:        post,
 		CommunityName: communityInfo.CommunityName,
+		VoteUp:        voteData[0].Up,
+		VoteDown:      voteData[0].Down,
+		Score:         voteData[0].Score,
+		CommentCount:  commentCounts[pid],
 	}
 	controller.ResponseSuccess(ctx, data)
 }
 
+// canViewPost 已发布的帖子谁都能看，草稿只有作者能看，隐藏的帖子作者和版主能看
+func canViewPost(userID int64, p DBPost) (bool, error) {
+	switch p.Status {
+	case StatusPublished:
+		return true, nil
+	case StatusDraft:
+		return p.AuthorID == userID, nil
+	case StatusHidden:
+		return canManagePost(userID, p)
+	default:
+		return false, nil
+	}
+}
+
+// canManagePost 作者和帖子所在社区的版主可以修改和删除帖子
+func canManagePost(userID int64, p DBPost) (bool, error) {
+	if p.AuthorID == userID {
+		return true, nil
+	}
+	return community.IsModerator(p.CommunityID, userID)
+}
+
+// getVisiblePost 查询当前用户能看到的帖子，看不到的帖子当成不存在
+// 返回 false 的时候已经写好了响应
+func getVisiblePost(ctx *gin.Context, pid int64) (DBPost, bool) {
+	post, err := GetPostByID(pid)
+	if errors.Is(err, sql.ErrNoRows) {
+		controller.ResponseError(ctx, controller.CodePostNotExist)
+		return DBPost{}, false
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("GetPostByID failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return DBPost{}, false
+	}
+
+	// 没有登录的话 userID 是 0，只能看已发布的帖子
+	userID, _ := user.GetCurrentUserID(ctx)
+	ok, err := canViewPost(userID, post)
+	if err != nil {
+		logger.Ctx(ctx).Error("canViewPost failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return DBPost{}, false
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodePostNotExist)
+		return DBPost{}, false
+	}
+	return post, true
+}
+
+// getManageablePost 查询当前用户能修改的帖子
+// 返回 false 的时候已经写好了响应
+func getManageablePost(ctx *gin.Context) (DBPost, int64, bool) {
+	pid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return DBPost{}, 0, false
+	}
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return DBPost{}, 0, false
+	}
+
+	post, ok := getVisiblePost(ctx, pid)
+	if !ok {
+		return DBPost{}, 0, false
+	}
+	ok, err = canManagePost(userID, post)
+	if err != nil {
+		logger.Ctx(ctx).Error("canManagePost failed",
+			zap.Int64("pid", pid),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return DBPost{}, 0, false
+	}
+	if !ok {
+		controller.ResponseError(ctx, controller.CodeNoPermission)
+		return DBPost{}, 0, false
+	}
+	return post, userID, true
+}
+
+// postUpdateReq 修改帖子的请求参数，没有传的字段不修改
+type postUpdateReq struct {
+	Title   *string `json:"title" binding:"omitempty,min=1"`
+	Content *string `json:"content" binding:"omitempty,min=1"`
+	Status  string  `json:"status" binding:"omitempty,oneof=draft published hidden"`
+}
+
+// UpdatePostHandler 修改帖子，只有作者和版主可以修改，只有版主可以隐藏和取消隐藏
+// PUT /api/v1/post/:id
+func UpdatePostHandler(ctx *gin.Context) {
+	req := postUpdateReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.Ctx(ctx).Error("update post with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	old, userID, ok := getManageablePost(ctx)
+	if !ok {
+		return
+	}
+
+	post := old
+	if req.Title != nil {
+		post.Title = *req.Title
+	}
+	if req.Content != nil {
+		post.Content = *req.Content
+	}
+	if req.Status != "" {
+		post.Status = statusNames[req.Status]
+	}
+	// 发布之后不能再变回草稿
+	if post.Status == StatusDraft && old.Status != StatusDraft {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	// 隐藏和取消隐藏只有版主可以，作者不能撤销版主的隐藏
+	if post.Status != old.Status && (post.Status == StatusHidden || old.Status == StatusHidden) {
+		isModerator, err := community.IsModerator(post.CommunityID, userID)
+		if err != nil {
+			logger.Ctx(ctx).Error("community.IsModerator failed",
+				zap.Int64("pid", post.ID),
+				zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			return
+		}
+		if !isModerator {
+			controller.ResponseError(ctx, controller.CodeNoPermission)
+			return
+		}
+	}
+
+	if err := UpdatePost(ctx.Request.Context(), old, post, userID); err != nil {
+		logger.Ctx(ctx).Error("UpdatePost failed",
+			zap.Int64("pid", post.ID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, nil)
+}
+
+// DeletePostHandler 删除帖子，只有作者和版主可以删除
+// DELETE /api/v1/post/:id
+func DeletePostHandler(ctx *gin.Context) {
+	post, _, ok := getManageablePost(ctx)
+	if !ok {
+		return
+	}
+
+	if err := DeletePost(ctx.Request.Context(), post); err != nil {
+		logger.Ctx(ctx).Error("DeletePost failed",
+			zap.Int64("pid", post.ID),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, nil)
+}
+
 // 获取帖子列表
-func getPostList(page, size int64) ([]ApiPostDetail, error) {
+func getPostList(ctx context.Context, page, size int64) ([]ApiPostDetail, error) {
 	// 获取数据
 	posts, err := GetDBPostList(page, size)
 	if err != nil {
 		return nil, err
 	}
 
-	data := make([]ApiPostDetail, 0, len(posts))
+	return assemblePostDetails(ctx, posts)
+}
+
+// 从游标的位置开始获取帖子列表，多查一条用来判断是否还有下一页
+func getPostListByCursor(ctx context.Context, c cursor.Cursor, size int64) (cursor.Page, error) {
+	posts, err := GetDBPostListByCursor(c, size+1)
+	if err != nil {
+		return cursor.Page{}, err
+	}
 
+	hasMore := int64(len(posts)) > size
+	if hasMore {
+		posts = posts[:size]
+	}
+	data, err := assemblePostDetails(ctx, posts)
+	if err != nil {
+		return cursor.Page{}, err
+	}
+
+	page := cursor.Page{List: data, HasMore: hasMore}
+	if hasMore {
+		last := posts[len(posts)-1]
+		page.NextCursor = cursor.Cursor{Time: last.CreateTime, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+// assemblePostDetails 给帖子补上作者、社区、投票和评论数
+// 作者和社区通过请求级别的 loader 批量查询，查询的次数和帖子的数量无关
+func assemblePostDetails(ctx context.Context, posts []DBPost) ([]ApiPostDetail, error) {
+	ids := make([]int64, 0, len(posts))
+	authorIDs := make([]int64, 0, len(posts))
+	communityIDs := make([]int64, 0, len(posts))
 	for _, p := range posts {
-		// 根据作者 id 查询作者信息
-		u, err := user.GetUserById(p.AuthorID)
-		if err != nil {
-			zap.L().Error("GetUserById failed",
-				zap.Int64("author_id", p.AuthorID),
-				zap.Error(err))
-			return nil, err
-		}
+		ids = append(ids, p.ID)
+		authorIDs = append(authorIDs, p.AuthorID)
+		communityIDs = append(communityIDs, p.CommunityID)
+	}
+	voteData, err := redis.GetPostVoteData(ctx, ids)
+	if err != nil {
+		logger.Ctx(ctx).Error("GetPostVoteData failed", zap.Error(err))
+		return nil, err
+	}
 
-		// 根据社区 id 查询社区详细信息
-		communityInfo, err := community.GetCommunityDetailByID(p.CommunityID)
-		if err != nil {
-			zap.L().Error("GetCommunityDetailByID failed",
-				zap.Int64("community_id", p.CommunityID),
-				zap.Error(err))
-			return nil, err
-		}
+	// 根据作者 id 查询作者信息
+	users, err := loader.Users(ctx, authorIDs)
+	if err != nil {
+		logger.Ctx(ctx).Error("loader.Users failed", zap.Error(err))
+		return nil, err
+	}
 
+	// 根据社区 id 查询社区详细信息
+	communities, err := loader.Communities(ctx, communityIDs)
+	if err != nil {
+		logger.Ctx(ctx).Error("loader.Communities failed", zap.Error(err))
+		return nil, err
+	}
+
+	commentCounts, err := getCommentCounts(ids)
+	if err != nil {
+		logger.Ctx(ctx).Error("getCommentCounts failed", zap.Error(err))
+		return nil, err
+	}
+
+	data := make([]ApiPostDetail, 0, len(posts))
+	for i, p := range posts {
 		postDetail := ApiPostDetail{
 			DBPost:        p,
-			AuthorName:    u.Username,
-			CommunityName: communityInfo.CommunityName,
+			CommunityName: communities[p.CommunityID].CommunityName,
+			VoteUp:        voteData[i].Up,
+			VoteDown:      voteData[i].Down,
+			Score:         voteData[i].Score,
+			CommentCount:  commentCounts[p.ID],
+		}
+		// 作者被删除的时候作者名为空
+		if u, ok := users[p.AuthorID]; ok {
+			postDetail.AuthorName = u.Username
 		}
 		data = append(data, postDetail)
 	}
@@ -145,6 +420,9 @@ func getPostList(page, size int64) ([]ApiPostDetail, error) {
 	return data, nil
 }
 
+// maxPageSize 每一页最多的帖子数，和 postListReq 的 max 保持一致
+const maxPageSize = 100
+
 func getPageInfo(ctx *gin.Context) (int64, int64) {
 	pageStr := ctx.Query("page")
 	sizeStr := ctx.Query("size")
@@ -163,13 +441,83 @@ func getPageInfo(ctx *gin.Context) (int64, int64) {
 }
 
 // GetPostListHandler 获取帖子列表的处理函数
+// 带了 cursor 参数（第一页传空字符串）的时候使用游标分页，返回 list、next_cursor 和 has_more，
+// 否则按照 page 和 size 分页，直接返回帖子列表
 func GetPostListHandler(ctx *gin.Context) {
 	// 获取分页参数
 	page, size := getPageInfo(ctx)
+	if page < 1 || size < 1 || size > maxPageSize {
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+	if cursorStr, ok := ctx.GetQuery("cursor"); ok {
+		c, err := cursor.Decode(cursorStr)
+		if err != nil {
+			controller.ResponseError(ctx, controller.CodeInvalidParam)
+			return
+		}
+		data, err := getPostListByCursor(ctx.Request.Context(), c, size)
+		if err != nil {
+			logger.Ctx(ctx).Error("getPostListByCursor() failed", zap.Error(err))
+			controller.ResponseError(ctx, controller.CodeServerBusy)
+			return
+		}
+		controller.ResponseSuccess(ctx, data)
+		return
+	}
+
 	// 获取数据
-	data, err := getPostList(page, size)
+	data, err := getPostList(ctx.Request.Context(), page, size)
+	if err != nil {
+		logger.Ctx(ctx).Error("GetPostList() failed", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResponseSuccess(ctx, data)
+}
+
+// postListReq 按照时间或者分数排序的帖子列表的请求参数
+type postListReq struct {
+	Order       string `form:"order" binding:"omitempty,oneof=time score"`
+	CommunityID int64  `form:"community_id"`
+	Page        int64  `form:"page" binding:"min=1"`
+	Size        int64  `form:"size" binding:"min=1,max=100"`
+}
+
+// 获取按照时间或者分数排序的帖子列表
+func getPostListInOrder(ctx context.Context, req postListReq) ([]ApiPostDetail, error) {
+	// 先从 redis 查出这一页的帖子 id，再去 MySQL 查帖子数据
+	ids, err := redis.GetPostIDsInOrder(ctx, req.Order, req.CommunityID, req.Page, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := GetPostListByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return assemblePostDetails(ctx, posts)
+}
+
+// GetPostListHandlerV2 获取按照时间或者分数排序的帖子列表，可以按照社区过滤
+// GET /api/v2/posts?order=time|score&community_id=1&page=1&size=10
+func GetPostListHandlerV2(ctx *gin.Context) {
+	req := postListReq{
+		Order: redis.OrderTime,
+		Page:  1,
+		Size:  10,
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logger.Ctx(ctx).Error("get post list with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	data, err := getPostListInOrder(ctx.Request.Context(), req)
 	if err != nil {
-		zap.L().Error("GetPostList() failed", zap.Error(err))
+		logger.Ctx(ctx).Error("getPostListInOrder() failed", zap.Error(err))
 		controller.ResponseError(ctx, controller.CodeServerBusy)
 		return
 	}
@@ -0,0 +1,328 @@
+package post
+
+import (
+	"bookstore/web_app/conf"
+	"bookstore/web_app/controller"
+	"bookstore/web_app/dao/mysql/mysqltest"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/middlewares"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试里用到的帖子、作者、版主和社区
+const (
+	testPostID      int64 = 1
+	testAuthorID    int64 = 100
+	testModeratorID int64 = 200
+	testOtherID     int64 = 300
+	testCommunityID int64 = 10
+)
+
+var postColumns = []string{"post_id", "title", "content", "author_id", "community_id", "status", "create_time"}
+
+// newTestRedis 启动一个进程内的 miniredis，并且让 redis 包连上它
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+	require.NoError(t, redis.Init(&conf.RedisConfig{Host: mr.Host(), Port: port}))
+	t.Cleanup(redis.Close)
+	return mr
+}
+
+// doRequest 调用 handler，userID 不为 0 的时候模拟已经登录，返回响应里的 code
+func doRequest(t *testing.T, route string, handler gin.HandlerFunc, method, path, body string, userID int64) controller.ResCode {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		if userID != 0 {
+			ctx.Set(middlewares.CtxUserIDKey, userID)
+		}
+	})
+	r.Handle(method, route, handler)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp struct {
+		Code controller.ResCode `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp.Code
+}
+
+func expectGetPost(mock sqlmock.Sqlmock, status int32) {
+	mock.ExpectQuery(`from post where post_id = \?`).
+		WithArgs(testPostID).
+		WillReturnRows(sqlmock.NewRows(postColumns).
+			AddRow(testPostID, "title", "content", testAuthorID, testCommunityID, status, time.Now()))
+}
+
+func expectIsModerator(mock sqlmock.Sqlmock, userID int64, isModerator bool) {
+	count := 0
+	if isModerator {
+		count = 1
+	}
+	mock.ExpectQuery(`from community_moderator`).
+		WithArgs(testCommunityID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(count))
+}
+
+func TestCreatePostHandler(t *testing.T) {
+	expectGetCommunity := func(mock sqlmock.Sqlmock, private bool) {
+		mock.ExpectQuery(`from community\s+where community_id = \?`).
+			WithArgs(testCommunityID).
+			WillReturnRows(sqlmock.NewRows([]string{"community_id", "community_name", "introduction", "private", "create_time"}).
+				AddRow(testCommunityID, "go", "", private, time.Now()))
+	}
+
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name: "community not exist",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`from community\s+where community_id = \?`).
+					WithArgs(testCommunityID).
+					WillReturnRows(sqlmock.NewRows([]string{"community_id"}))
+			},
+			wantCode: controller.CodeCommunityNotExist,
+		},
+		{
+			name: "non-member in private community",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetCommunity(mock, true)
+				mock.ExpectQuery(`from community_member`).
+					WithArgs(testCommunityID, testOtherID).
+					WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(0))
+			},
+			wantCode: controller.CodeNotCommunityMember,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestRedis(t)
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post", CreatePostHandler, http.MethodPost, "/post",
+				`{"community_id":10,"title":"title","content":"content"}`, testOtherID)
+			assert.Equal(t, tc.wantCode, code)
+
+			ids, err := redis.GetPostIDsInOrder(context.Background(), redis.OrderTime, 0, 1, 10)
+			require.NoError(t, err)
+			assert.Empty(t, ids)
+		})
+	}
+}
+
+func TestGetPostDetailHandler(t *testing.T) {
+	// 能看到帖子的时候还要查作者和社区
+	expectDetail := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`from user where user_id = \?`).
+			WithArgs(testAuthorID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "token_version"}).
+				AddRow(testAuthorID, "author", 0))
+		mock.ExpectQuery(`from community`).
+			WithArgs(testCommunityID).
+			WillReturnRows(sqlmock.NewRows([]string{"community_id", "community_name", "introduction", "private", "create_time"}).
+				AddRow(testCommunityID, "go", "", false, time.Now()))
+	}
+
+	testCases := []struct {
+		name   string
+		userID int64
+		mock   func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+	}{
+		{
+			name: "published without login",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusPublished)
+				expectDetail(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "draft by author",
+			userID: testAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusDraft)
+				expectDetail(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "draft by others",
+			userID: testModeratorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusDraft)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name:   "hidden by moderator",
+			userID: testModeratorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusHidden)
+				expectIsModerator(mock, testModeratorID, true)
+				expectDetail(mock)
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "hidden by others",
+			userID: testOtherID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusHidden)
+				expectIsModerator(mock, testOtherID, false)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name:   "deleted by author",
+			userID: testAuthorID,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusDeleted)
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+		{
+			name: "not exist",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`from post where post_id = \?`).
+					WithArgs(testPostID).
+					WillReturnRows(sqlmock.NewRows(postColumns))
+			},
+			wantCode: controller.CodePostNotExist,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestRedis(t)
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post/:id", GetPostDetailHandler, http.MethodGet, "/post/1", "", tc.userID)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestUpdatePostHandler(t *testing.T) {
+	testCases := []struct {
+		name   string
+		userID int64
+		body   string
+		mock   func(mock sqlmock.Sqlmock)
+
+		wantCode controller.ResCode
+		// wantInList 修改之后帖子是不是在 redis 的帖子列表里
+		wantInList bool
+	}{
+		{
+			name:   "others",
+			userID: testOtherID,
+			body:   `{"title":"new"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusPublished)
+				expectIsModerator(mock, testOtherID, false)
+			},
+			wantCode: controller.CodeNoPermission,
+		},
+		{
+			name:   "author hides",
+			userID: testAuthorID,
+			body:   `{"status":"hidden"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusPublished)
+				expectIsModerator(mock, testAuthorID, false)
+			},
+			wantCode: controller.CodeNoPermission,
+		},
+		{
+			name:   "author publishes hidden",
+			userID: testAuthorID,
+			body:   `{"status":"published"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusHidden)
+				expectIsModerator(mock, testAuthorID, false)
+			},
+			wantCode: controller.CodeNoPermission,
+		},
+		{
+			name:   "published to draft",
+			userID: testAuthorID,
+			body:   `{"status":"draft"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusPublished)
+			},
+			wantCode: controller.CodeInvalidParam,
+		},
+		{
+			name:   "moderator hides",
+			userID: testModeratorID,
+			body:   `{"status":"hidden"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusPublished)
+				expectIsModerator(mock, testModeratorID, true)
+				expectIsModerator(mock, testModeratorID, true)
+				mock.ExpectBegin()
+				mock.ExpectExec(`update post set`).
+					WithArgs("title", "content", StatusHidden, sqlmock.AnyArg(), testPostID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCode: controller.CodeSuccess,
+		},
+		{
+			name:   "author publishes draft",
+			userID: testAuthorID,
+			body:   `{"status":"published"}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectGetPost(mock, StatusDraft)
+				mock.ExpectBegin()
+				mock.ExpectExec(`update post set`).
+					WithArgs("title", "content", StatusPublished, sqlmock.AnyArg(), testPostID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCode:   controller.CodeSuccess,
+			wantInList: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestRedis(t)
+			mock := mysqltest.New(t)
+			tc.mock(mock)
+
+			code := doRequest(t, "/post/:id", UpdatePostHandler, http.MethodPut, "/post/1", tc.body, tc.userID)
+			assert.Equal(t, tc.wantCode, code)
+
+			ids, err := redis.GetPostIDsInOrder(context.Background(), redis.OrderTime, 0, 1, 10)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantInList, len(ids) > 0)
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package router
 
 import (
+	"bookstore/web_app/comment"
 	"bookstore/web_app/community"
 	"bookstore/web_app/controller"
+	"bookstore/web_app/loader"
 	"bookstore/web_app/logger"
 	"bookstore/web_app/middlewares"
 	"bookstore/web_app/post"
+	"bookstore/web_app/vote"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +20,7 @@ func SetupRouter(mode string) *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	r := gin.New()
-	r.Use(logger.GinLogger(), logger.GinRecovery(true))
+	r.Use(middlewares.RequestIDMiddleware(), logger.GinLogger(), logger.GinRecovery(true))
 
 	v1 := r.Group("/api/v1")
 	// 注册业务路由
@@ -26,14 +29,40 @@ func SetupRouter(mode string) *gin.Engine {
 	v1.POST("/login", controller.LoginHandler)
 
 	// 应用 JWT 认证中间件
-	v1.Use(middlewares.JWTAuthMiddleware())
+	v1.Use(middlewares.JWTAuthMiddleware(), loader.Middleware())
 
 	{
 		v1.GET("/community", community.GetCommunityConf)
 		v1.GET("/community/:id", community.GetCommunityDetail)
+		v1.POST("/community", community.CreateCommunityHandler)
+		v1.PUT("/community/:id", community.UpdateCommunityHandler)
+		v1.POST("/community/:id/members", community.JoinCommunityHandler)
+		v1.DELETE("/community/:id/members", community.LeaveCommunityHandler)
+		v1.PUT("/community/:id/members/:uid", community.AddMemberHandler)
+		v1.PUT("/community/:id/moderators/:uid", community.AddModeratorHandler)
+		v1.DELETE("/community/:id/moderators/:uid", community.RemoveModeratorHandler)
+		v1.GET("/me", controller.GetMeHandler)
+		v1.PUT("/me", controller.UpdateMeHandler)
+		v1.PUT("/me/password", controller.ChangePasswordHandler)
+		v1.DELETE("/me", controller.DeleteMeHandler)
+		v1.GET("/users/:id", controller.GetUserProfileHandler)
+		v1.GET("/users/:id/communities", community.GetUserCommunitiesHandler)
+		v1.GET("/search", controller.SearchHandler)
 		v1.POST("/post", post.CreatePostHandler)
 		v1.POST("/post/:id", post.GetPostDetailHandler)
+		v1.PUT("/post/:id", post.UpdatePostHandler)
+		v1.DELETE("/post/:id", post.DeletePostHandler)
+		v1.POST("/post/:id/comments", comment.CreateCommentHandler)
+		v1.GET("/post/:id/comments", comment.GetCommentListHandler)
+		v1.DELETE("/post/:id/comments/:cid", comment.DeleteCommentHandler)
 		v1.POST("/list-posts", post.GetPostListHandler)
+		v1.POST("/vote", vote.PostVoteHandler)
+	}
+
+	v2 := r.Group("/api/v2")
+	v2.Use(middlewares.JWTAuthMiddleware(), loader.Middleware())
+	{
+		v2.GET("/posts", post.GetPostListHandlerV2)
 	}
 
 	r.NoRoute(func(ctx *gin.Context) {
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 高亮的标签
+const (
+	highlightPre  = "<em>"
+	highlightPost = "</em>"
+)
+
+// interval 命中的区间，单位是 rune
+type interval struct {
+	start, end int
+}
+
+// isWordRune 英文和数字的词不能从单词中间开始或者结束
+func isWordRune(r rune) bool {
+	return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// matchIntervals 找出 text 里命中 tokens 的区间，重叠的区间会被合并
+// 和分词保持一致，英文和数字的词要整词匹配，比如 go 不会命中 golang
+func matchIntervals(runes []rune, tokens []string) []interval {
+	lower := []rune(normalize(string(runes)))
+	var res []interval
+	for _, token := range tokens {
+		t := []rune(token)
+		if len(t) == 0 {
+			continue
+		}
+		word := isWordRune(t[0])
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != token {
+				continue
+			}
+			if word && ((i > 0 && isWordRune(lower[i-1])) ||
+				(i+len(t) < len(lower) && isWordRune(lower[i+len(t)]))) {
+				continue
+			}
+			res = append(res, interval{start: i, end: i + len(t)})
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].start < res[j].start
+	})
+	merged := res[:1]
+	for _, in := range res[1:] {
+		last := &merged[len(merged)-1]
+		if in.start <= last.end {
+			if in.end > last.end {
+				last.end = in.end
+			}
+			continue
+		}
+		merged = append(merged, in)
+	}
+	return merged
+}
+
+// highlight 用 <em> 标出命中的部分，其余的内容做 HTML 转义
+func highlight(runes []rune, intervals []interval) string {
+	var sb strings.Builder
+	pos := 0
+	for _, in := range intervals {
+		sb.WriteString(html.EscapeString(string(runes[pos:in.start])))
+		sb.WriteString(highlightPre)
+		sb.WriteString(html.EscapeString(string(runes[in.start:in.end])))
+		sb.WriteString(highlightPost)
+		pos = in.end
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:])))
+	return sb.String()
+}
+
+// Highlight 高亮 text 里所有命中 tokens 的部分
+func Highlight(text string, tokens []string) string {
+	runes := []rune(text)
+	return highlight(runes, matchIntervals(runes, tokens))
+}
+
+// Snippet 截取 text 里第一个命中的位置附近最多 maxRunes 个字并高亮
+// 没有命中的时候截取开头
+func Snippet(text string, tokens []string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return highlight(runes, matchIntervals(runes, tokens))
+	}
+
+	start := 0
+	if intervals := matchIntervals(runes, tokens); len(intervals) > 0 {
+		// 命中的位置前面留一点上下文
+		start = intervals[0].start - maxRunes/4
+		if start < 0 {
+			start = 0
+		}
+		if start+maxRunes > len(runes) {
+			start = len(runes) - maxRunes
+		}
+	}
+	end := start + maxRunes
+	window := runes[start:end]
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	sb.WriteString(highlight(window, matchIntervals(window, tokens)))
+	if end < len(runes) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
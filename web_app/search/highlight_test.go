@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlight(t *testing.T) {
+	testCases := []struct {
+		name   string
+		text   string
+		tokens []string
+		want   string
+	}{
+		{
+			name:   "case insensitive",
+			text:   "Learn Go, go!",
+			tokens: []string{"go"},
+			want:   "Learn <em>Go</em>, <em>go</em>!",
+		},
+		{
+			name:   "merge overlapping bigrams",
+			text:   "欢迎来到英雄联盟",
+			tokens: []string{"英雄", "雄联", "联盟"},
+			want:   "欢迎来到<em>英雄联盟</em>",
+		},
+		{
+			name:   "escape",
+			text:   "<b>go</b>",
+			tokens: []string{"go"},
+			want:   "&lt;b&gt;<em>go</em>&lt;/b&gt;",
+		},
+		{
+			name:   "whole word",
+			text:   "golang go 1go go1 Go语言",
+			tokens: []string{"go"},
+			want:   "golang <em>go</em> 1go go1 <em>Go</em>语言",
+		},
+		{
+			name:   "no match",
+			text:   "rush b",
+			tokens: []string{"go"},
+			want:   "rush b",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Highlight(tc.text, tc.tokens))
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", 50) + " golang " + strings.Repeat("b", 50)
+
+	testCases := []struct {
+		name     string
+		text     string
+		tokens   []string
+		maxRunes int
+		want     string
+	}{
+		{
+			name:     "short",
+			text:     "hello golang",
+			tokens:   []string{"golang"},
+			maxRunes: 20,
+			want:     "hello <em>golang</em>",
+		},
+		{
+			name:     "around match",
+			text:     long,
+			tokens:   []string{"golang"},
+			maxRunes: 20,
+			want:     "..." + strings.Repeat("a", 4) + " <em>golang</em> " + strings.Repeat("b", 8) + "...",
+		},
+		{
+			name:     "match at the end",
+			text:     strings.Repeat("a", 50) + " go",
+			tokens:   []string{"go"},
+			maxRunes: 10,
+			want:     "..." + strings.Repeat("a", 7) + " <em>go</em>",
+		},
+		{
+			name:     "no match",
+			text:     long,
+			tokens:   []string{"rust"},
+			maxRunes: 10,
+			want:     strings.Repeat("a", 10) + "...",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Snippet(tc.text, tc.tokens, tc.maxRunes))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"errors"
+)
+
+// 可以搜索的数据类型
+const (
+	TypePost      = "post"
+	TypeCommunity = "community"
+)
+
+// snippetLength 搜索结果里内容摘要的长度
+const snippetLength = 80
+
+var (
+	ErrEmptyQuery     = errors.New("search: 搜索关键词为空")
+	ErrNotInitialized = errors.New("search: 没有调用 Init")
+)
+
+// Document 被索引的数据
+// 帖子的 Title 和 Content 是标题和内容，社区的是名字和简介
+type Document struct {
+	Type    string
+	ID      int64
+	Title   string
+	Content string
+}
+
+// Query 搜索条件
+type Query struct {
+	Q string
+	// Type 只搜索某种数据，为空表示都搜索
+	Type string
+	// Page 从 1 开始
+	Page int64
+	Size int64
+}
+
+// Hit 一条搜索结果，Title 和 Snippet 已经高亮并且做过 HTML 转义
+type Hit struct {
+	Type    string  `json:"type"`
+	ID      int64   `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+// Result 搜索结果
+type Result struct {
+	Total int64 `json:"total"`
+	Hits  []Hit `json:"hits"`
+}
+
+// Indexer 搜索的抽象，MySQLIndexer 直接用表上的 FULLTEXT 索引，MemoryIndexer 在进程内维护倒排索引
+type Indexer interface {
+	// Index 新增或者更新数据
+	Index(ctx context.Context, docs ...Document) error
+	// Delete 删除数据，删除之后搜不到
+	Delete(ctx context.Context, typ string, id int64) error
+	Search(ctx context.Context, q Query) (Result, error)
+}
+
+// newHit 生成高亮之后的搜索结果
+func newHit(doc Document, tokens []string, score float64) Hit {
+	return Hit{
+		Type:    doc.Type,
+		ID:      doc.ID,
+		Title:   Highlight(doc.Title, tokens),
+		Snippet: Snippet(doc.Content, tokens, snippetLength),
+		Score:   score,
+	}
+}
@@ -0,0 +1,165 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// titleWeight 标题里命中的权重
+const titleWeight = 2
+
+type docKey struct {
+	typ string
+	id  int64
+}
+
+// MemoryIndexer 进程内的倒排索引，重启之后需要重新建索引
+// 适合测试和数据量不大的部署
+type MemoryIndexer struct {
+	mu sync.RWMutex
+	// postings 词到文档以及词频的映射，标题里的词频乘以 titleWeight
+	postings map[string]map[docKey]int
+	docs     map[docKey]Document
+}
+
+func NewMemoryIndexer() *MemoryIndexer {
+	return &MemoryIndexer{
+		postings: make(map[string]map[docKey]int),
+		docs:     make(map[docKey]Document),
+	}
+}
+
+func (m *MemoryIndexer) Index(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, doc := range docs {
+		key := docKey{typ: doc.Type, id: doc.ID}
+		m.remove(key)
+		m.docs[key] = doc
+		for _, token := range Tokenize(doc.Title) {
+			m.add(token, key, titleWeight)
+		}
+		for _, token := range Tokenize(doc.Content) {
+			m.add(token, key, 1)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryIndexer) add(token string, key docKey, weight int) {
+	docs, ok := m.postings[token]
+	if !ok {
+		docs = make(map[docKey]int)
+		m.postings[token] = docs
+	}
+	docs[key] += weight
+}
+
+// remove 删除文档之前的索引，调用方需要持有写锁
+func (m *MemoryIndexer) remove(key docKey) {
+	doc, ok := m.docs[key]
+	if !ok {
+		return
+	}
+	for _, token := range append(Tokenize(doc.Title), Tokenize(doc.Content)...) {
+		docs := m.postings[token]
+		delete(docs, key)
+		if len(docs) == 0 {
+			delete(m.postings, token)
+		}
+	}
+	delete(m.docs, key)
+}
+
+func (m *MemoryIndexer) Delete(ctx context.Context, typ string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(docKey{typ: typ, id: id})
+	return nil
+}
+
+// Search 返回包含所有关键词的文档，按照 TF-IDF 的分数从高到低排序
+func (m *MemoryIndexer) Search(ctx context.Context, q Query) (Result, error) {
+	tokens := QueryTokens(q.Q)
+	if len(tokens) == 0 {
+		return Result{}, ErrEmptyQuery
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// 先从文档最少的词开始取交集
+	lists := make([]map[docKey]int, 0, len(tokens))
+	for _, token := range tokens {
+		docs, ok := m.postings[token]
+		if !ok {
+			return Result{Hits: []Hit{}}, nil
+		}
+		lists = append(lists, docs)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+
+	total := float64(len(m.docs))
+	scores := make(map[docKey]float64)
+	for key := range lists[0] {
+		if q.Type != "" && key.typ != q.Type {
+			continue
+		}
+		var score float64
+		matched := true
+		for _, docs := range lists {
+			tf, ok := docs[key]
+			if !ok {
+				matched = false
+				break
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			score += float64(tf) * idf
+		}
+		if matched {
+			scores[key] = score
+		}
+	}
+
+	keys := make([]docKey, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		// 分数一样的时候新的数据在前面
+		if keys[i].id != keys[j].id {
+			return keys[i].id > keys[j].id
+		}
+		return keys[i].typ < keys[j].typ
+	})
+
+	res := Result{Total: int64(len(keys)), Hits: []Hit{}}
+	start, end := pageRange(q.Page, q.Size, int64(len(keys)))
+	for _, key := range keys[start:end] {
+		res.Hits = append(res.Hits, newHit(m.docs[key], tokens, scores[key]))
+	}
+	return res, nil
+}
+
+// pageRange 第 page 页在长度为 n 的结果里的下标范围
+func pageRange(page, size, n int64) (int64, int64) {
+	start := (page - 1) * size
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := start + size
+	if end > n {
+		end = n
+	}
+	return start, end
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIndexer_Search(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryIndexer()
+	require.NoError(t, m.Index(ctx,
+		Document{Type: TypePost, ID: 1, Title: "Go 语言入门", Content: "从零开始学习 Go 语言"},
+		Document{Type: TypePost, ID: 2, Title: "刷题笔记", Content: "用 Go 刷 leetcode"},
+		Document{Type: TypePost, ID: 3, Title: "英雄联盟攻略", Content: "上单怎么玩"},
+		Document{Type: TypeCommunity, ID: 1, Title: "Go", Content: "Golang"},
+		Document{Type: TypeCommunity, ID: 4, Title: "LOL", Content: "欢迎来到英雄联盟"},
+	))
+
+	testCases := []struct {
+		name      string
+		q         Query
+		wantTotal int64
+		wantHits  []Hit
+		wantErr   error
+	}{
+		{
+			name:    "empty",
+			q:       Query{Q: " ,. ", Page: 1, Size: 10},
+			wantErr: ErrEmptyQuery,
+		},
+		{
+			name:     "no match",
+			q:        Query{Q: "rust", Page: 1, Size: 10},
+			wantHits: []Hit{},
+		},
+		{
+			name:      "title matches rank first",
+			q:         Query{Q: "英雄联盟", Page: 1, Size: 10},
+			wantTotal: 2,
+			wantHits: []Hit{
+				{Type: TypePost, ID: 3, Title: "<em>英雄联盟</em>攻略", Snippet: "上单怎么玩"},
+				{Type: TypeCommunity, ID: 4, Title: "LOL", Snippet: "欢迎来到<em>英雄联盟</em>"},
+			},
+		},
+		{
+			name:      "all tokens must match",
+			q:         Query{Q: "go 语言", Page: 1, Size: 10},
+			wantTotal: 1,
+			wantHits: []Hit{
+				{Type: TypePost, ID: 1, Title: "<em>Go</em> <em>语言</em>入门", Snippet: "从零开始学习 <em>Go</em> <em>语言</em>"},
+			},
+		},
+		{
+			name:      "type",
+			q:         Query{Q: "go", Type: TypeCommunity, Page: 1, Size: 10},
+			wantTotal: 1,
+			wantHits: []Hit{
+				{Type: TypeCommunity, ID: 1, Title: "<em>Go</em>", Snippet: "Golang"},
+			},
+		},
+		{
+			name:      "single chinese character",
+			q:         Query{Q: "刷", Page: 1, Size: 10},
+			wantTotal: 1,
+			wantHits: []Hit{
+				{Type: TypePost, ID: 2, Title: "<em>刷</em>题笔记", Snippet: "用 Go <em>刷</em> leetcode"},
+			},
+		},
+		{
+			name:      "page",
+			q:         Query{Q: "go", Page: 2, Size: 2},
+			wantTotal: 3,
+			wantHits: []Hit{
+				{Type: TypePost, ID: 2, Title: "刷题笔记", Snippet: "用 <em>Go</em> 刷 leetcode"},
+			},
+		},
+		{
+			name:      "out of range",
+			q:         Query{Q: "go", Page: 3, Size: 2},
+			wantTotal: 3,
+			wantHits:  []Hit{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := m.Search(ctx, tc.q)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantTotal, res.Total)
+			// 分数不好写死，只比较顺序和高亮
+			for i := range res.Hits {
+				assert.Greater(t, res.Hits[i].Score, 0.0)
+				res.Hits[i].Score = 0
+			}
+			assert.Equal(t, tc.wantHits, res.Hits)
+		})
+	}
+}
+
+func TestMemoryIndexer_Update(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryIndexer()
+	require.NoError(t, m.Index(ctx, Document{Type: TypePost, ID: 1, Title: "Go 入门", Content: "hello"}))
+
+	// 修改之后旧的内容搜不到
+	require.NoError(t, m.Index(ctx, Document{Type: TypePost, ID: 1, Title: "Rust 入门", Content: "hello"}))
+	res, err := m.Search(ctx, Query{Q: "go", Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), res.Total)
+	res, err = m.Search(ctx, Query{Q: "rust", Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Total)
+
+	require.NoError(t, m.Delete(ctx, TypePost, 1))
+	res, err = m.Search(ctx, Query{Q: "rust", Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), res.Total)
+	assert.Empty(t, m.postings)
+	assert.Empty(t, m.docs)
+}
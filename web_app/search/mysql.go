@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MySQLIndexer 使用 post 和 community 表上的 FULLTEXT 索引搜索，索引需要使用 ngram 解析器才能搜中文
+// 数据就在表里，所以 Index 和 Delete 什么都不用做，帖子的状态在查询的时候过滤
+type MySQLIndexer struct {
+	db *sqlx.DB
+}
+
+func NewMySQLIndexer(db *sqlx.DB) *MySQLIndexer {
+	return &MySQLIndexer{db: db}
+}
+
+func (m *MySQLIndexer) Index(ctx context.Context, docs ...Document) error {
+	return nil
+}
+
+func (m *MySQLIndexer) Delete(ctx context.Context, typ string, id int64) error {
+	return nil
+}
+
+// publishedPostStatus 已发布的帖子的状态，只搜索和加载这个状态的帖子
+// post 包依赖 search 包，所以由 post 包通过 SetPublishedPostStatus 设置，没有设置的时候搜不到帖子
+var publishedPostStatus int32 = -1
+
+// SetPublishedPostStatus 设置已发布的帖子的状态
+func SetPublishedPostStatus(status int32) {
+	publishedPostStatus = status
+}
+
+const (
+	postMatchSQL = `select 'post' as type, post_id as id, title, content,
+    MATCH(title, content) AGAINST(? IN BOOLEAN MODE) as score
+    from post
+    where status = ? and MATCH(title, content) AGAINST(? IN BOOLEAN MODE)`
+	// 和 MemoryIndexer 一样，社区名和简介都参与搜索
+	communityMatchSQL = `select 'community' as type, community_id as id, community_name as title, introduction as content,
+    MATCH(community_name, introduction) AGAINST(? IN BOOLEAN MODE) as score
+    from community
+    where MATCH(community_name, introduction) AGAINST(? IN BOOLEAN MODE)`
+)
+
+type mysqlHit struct {
+	Type    string  `db:"type"`
+	ID      int64   `db:"id"`
+	Title   string  `db:"title"`
+	Content string  `db:"content"`
+	Score   float64 `db:"score"`
+}
+
+func (m *MySQLIndexer) Search(ctx context.Context, q Query) (Result, error) {
+	against := booleanQuery(q.Q)
+	if against == "" {
+		return Result{}, ErrEmptyQuery
+	}
+
+	var parts []string
+	var args []any
+	if q.Type == "" || q.Type == TypePost {
+		parts = append(parts, postMatchSQL)
+		args = append(args, against, publishedPostStatus, against)
+	}
+	if q.Type == "" || q.Type == TypeCommunity {
+		parts = append(parts, communityMatchSQL)
+		args = append(args, against, against)
+	}
+	union := strings.Join(parts, "\nunion all\n")
+
+	var total int64
+	if err := m.db.GetContext(ctx, &total, `select count(1) from (`+union+`) t`, args...); err != nil {
+		return Result{}, err
+	}
+
+	var rows []mysqlHit
+	query := union + "\norder by score desc, id desc\nlimit ?, ?"
+	start, _ := pageRange(q.Page, q.Size, total)
+	if err := m.db.SelectContext(ctx, &rows, query, append(args, start, q.Size)...); err != nil {
+		return Result{}, err
+	}
+
+	tokens := QueryTokens(q.Q)
+	res := Result{Total: total, Hits: make([]Hit, 0, len(rows))}
+	for _, row := range rows {
+		doc := Document{Type: row.Type, ID: row.ID, Title: row.Title, Content: row.Content}
+		res.Hits = append(res.Hits, newHit(doc, tokens, row.Score))
+	}
+	return res, nil
+}
+
+// booleanQuery 把用户输入转成 BOOLEAN MODE 的查询，每个词都必须出现
+// 去掉用户输入里的运算符，避免被当成查询语法
+func booleanQuery(q string) string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-><()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, q)
+	words := strings.Fields(clean)
+	for i, w := range words {
+		words[i] = `+"` + w + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// LoadDocuments 从 MySQL 读出所有已发布的帖子和社区，用来给 MemoryIndexer 建索引
+func LoadDocuments(ctx context.Context, db *sqlx.DB) ([]Document, error) {
+	var rows []mysqlHit
+	err := db.SelectContext(ctx, &rows, `select 'post' as type, post_id as id, title, content, 0 as score
+    from post where status = ?
+    union all
+    select 'community' as type, community_id as id, community_name as title, introduction as content, 0 as score
+    from community`, publishedPostStatus)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]Document, 0, len(rows))
+	for _, row := range rows {
+		docs = append(docs, Document{Type: row.Type, ID: row.ID, Title: row.Title, Content: row.Content})
+	}
+	return docs, nil
+}
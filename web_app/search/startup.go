@@ -0,0 +1,84 @@
+package search
+
+import (
+	"bookstore/web_app/conf"
+	"bookstore/web_app/logger"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// 搜索的实现
+const (
+	BackendMemory = "memory"
+	BackendMySQL  = "mysql"
+)
+
+// indexer 全局使用的 Indexer，通过 Init 设置，没有设置的时候不能搜索
+var indexer Indexer
+
+// Init 根据配置创建 Indexer，内存索引会从 MySQL 读出所有数据建索引
+func Init(ctx context.Context, cfg *conf.SearchConfig, db *sqlx.DB) error {
+	backend := BackendMemory
+	if cfg != nil && cfg.Backend != "" {
+		backend = cfg.Backend
+	}
+	switch backend {
+	case BackendMySQL:
+		indexer = NewMySQLIndexer(db)
+	case BackendMemory:
+		m := NewMemoryIndexer()
+		docs, err := LoadDocuments(ctx, db)
+		if err != nil {
+			return err
+		}
+		if err = m.Index(ctx, docs...); err != nil {
+			return err
+		}
+		indexer = m
+	default:
+		return fmt.Errorf("search: 不支持的搜索实现 %s", backend)
+	}
+	return nil
+}
+
+// SetIndexer 替换全局的 Indexer
+func SetIndexer(i Indexer) {
+	indexer = i
+}
+
+// Search 使用全局的 Indexer 搜索
+func Search(ctx context.Context, q Query) (Result, error) {
+	if indexer == nil {
+		return Result{}, ErrNotInitialized
+	}
+	return indexer.Search(ctx, q)
+}
+
+// IndexDocument 数据新增或者修改之后调用，出错只记日志，不影响业务
+func IndexDocument(ctx context.Context, doc Document) {
+	if indexer == nil {
+		return
+	}
+	if err := indexer.Index(ctx, doc); err != nil {
+		logger.Ctx(ctx).Warn("search index failed",
+			zap.String("type", doc.Type),
+			zap.Int64("id", doc.ID),
+			zap.Error(err))
+	}
+}
+
+// DeleteDocument 数据删除或者不再公开之后调用，出错只记日志，不影响业务
+func DeleteDocument(ctx context.Context, typ string, id int64) {
+	if indexer == nil {
+		return
+	}
+	if err := indexer.Delete(ctx, typ, id); err != nil {
+		logger.Ctx(ctx).Warn("search delete failed",
+			zap.String("type", typ),
+			zap.Int64("id", id),
+			zap.Error(err))
+	}
+}
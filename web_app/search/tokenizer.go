@@ -0,0 +1,102 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 中文没有空格分词，没有词典的情况下按照 MySQL ngram 解析器的做法切成两个字一组
+// 英文和数字按照连续的字母数字切词，统一转成小写
+
+// segment 一段连续的文本，cjk 为 true 的时候是连续的中日韩文字
+type segment struct {
+	runes []rune
+	cjk   bool
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// segments 把文本切成连续的中文和字母数字片段，其余字符当成分隔符
+func segments(text string) []segment {
+	var res []segment
+	var cur []rune
+	curCJK := false
+	flush := func() {
+		if len(cur) > 0 {
+			res = append(res, segment{runes: cur, cjk: curCJK})
+			cur = nil
+		}
+	}
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			if !curCJK {
+				flush()
+			}
+			curCJK = true
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if curCJK {
+				flush()
+			}
+			curCJK = false
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return res
+}
+
+// Tokenize 建索引用的分词，中文同时输出单字和两个字一组的词，保证单字也能搜到
+func Tokenize(text string) []string {
+	var res []string
+	for _, seg := range segments(text) {
+		if !seg.cjk {
+			res = append(res, string(seg.runes))
+			continue
+		}
+		for i := range seg.runes {
+			res = append(res, string(seg.runes[i]))
+			if i+1 < len(seg.runes) {
+				res = append(res, string(seg.runes[i:i+2]))
+			}
+		}
+	}
+	return res
+}
+
+// QueryTokens 搜索用的分词，中文只有一个字的时候用单字，否则用两个字一组的词，结果去重
+func QueryTokens(q string) []string {
+	var res []string
+	seen := make(map[string]struct{})
+	add := func(token string) {
+		if _, ok := seen[token]; ok {
+			return
+		}
+		seen[token] = struct{}{}
+		res = append(res, token)
+	}
+	for _, seg := range segments(q) {
+		if !seg.cjk || len(seg.runes) == 1 {
+			add(string(seg.runes))
+			continue
+		}
+		for i := 0; i+1 < len(seg.runes); i++ {
+			add(string(seg.runes[i : i+2]))
+		}
+	}
+	return res
+}
+
+// normalize 转成小写，和分词的结果保持一致
+func normalize(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
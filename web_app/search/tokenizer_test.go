@@ -0,0 +1,76 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		name       string
+		text       string
+		wantTokens []string
+	}{
+		{
+			name:       "english",
+			text:       "Hello, Go-lang 1.20!",
+			wantTokens: []string{"hello", "go", "lang", "1", "20"},
+		},
+		{
+			name:       "chinese",
+			text:       "刷题啊",
+			wantTokens: []string{"刷", "刷题", "题", "题啊", "啊"},
+		},
+		{
+			name:       "mixed",
+			text:       "Go语言，入门",
+			wantTokens: []string{"go", "语", "语言", "言", "入", "入门", "门"},
+		},
+		{
+			name: "empty",
+			text: "  ,.!  ",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTokens, Tokenize(tc.text))
+		})
+	}
+}
+
+func TestQueryTokens(t *testing.T) {
+	testCases := []struct {
+		name       string
+		q          string
+		wantTokens []string
+	}{
+		{
+			name:       "english",
+			q:          "GO go Lang",
+			wantTokens: []string{"go", "lang"},
+		},
+		{
+			name:       "single chinese character",
+			q:          "题",
+			wantTokens: []string{"题"},
+		},
+		{
+			name:       "chinese",
+			q:          "英雄联盟",
+			wantTokens: []string{"英雄", "雄联", "联盟"},
+		},
+		{
+			name:       "mixed",
+			q:          "LOL 联盟",
+			wantTokens: []string{"lol", "联盟"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTokens, QueryTokens(tc.q))
+		})
+	}
+}
@@ -3,7 +3,11 @@ package user
 import (
 	"bookstore/web_app/code"
 	"bookstore/web_app/dao/mysql"
-	"bookstore/web_app/util"
+	"bookstore/web_app/pkg/password"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // 把每一步数据库操作封装成函数
@@ -31,8 +35,8 @@ func CheckUserExist(name string) error {
 
 func GetUserByName(name string) (*User, error) {
 	user := &User{}
-	sqlStr := `select user_id, username, password, email from user where username = ?`
-	err := db.Get(user, sqlStr, name)
+	sqlStr := `select user_id, username, password, email, token_version from user where username = ? and status = ?`
+	err := db.Get(user, sqlStr, name, StatusNormal)
 	if err != nil {
 		return nil, err
 	}
@@ -54,10 +58,13 @@ func GetUserByID(id int64) (*User, error) {
 // InsertUser 插入一条新的用户记录
 func InsertUser(user *User) error {
 	// 对密码进行加密
-	password := util.EncryptPassword(user.Password)
+	encoded, err := password.Hash(user.Password)
+	if err != nil {
+		return err
+	}
 	// 执行 SQL 语句入库
 	sqlStr := `insert into user(user_id, username, password, email) values(?,?,?,?)`
-	_, err := db.Exec(sqlStr, user.UserID, user.Username, password, user.Email)
+	_, err = db.Exec(sqlStr, user.UserID, user.Username, encoded, user.Email)
 
 	return err
 }
@@ -65,7 +72,127 @@ func InsertUser(user *User) error {
 // GetUserById 根据 id 获取用户信息
 func GetUserById(uid int64) (*User, error) {
 	user := &User{}
-	sqlStr := `select user_id, username from user where user_id = ?`
+	sqlStr := `select user_id, username, token_version from user where user_id = ?`
 	err := db.Get(user, sqlStr, uid)
 	return user, err
 }
+
+// GetUsersByIDs 一次查询多个用户，不存在的用户会被跳过，结果的顺序不固定
+func GetUsersByIDs(uids []int64) ([]*User, error) {
+	if len(uids) == 0 {
+		return []*User{}, nil
+	}
+	sqlStr := `select user_id, username from user where user_id in (?)`
+	query, args, err := sqlx.In(sqlStr, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(uids))
+	err = db.Select(&users, db.Rebind(query), args...)
+	return users, err
+}
+
+// IsAdmin 判断用户是不是管理员
+func IsAdmin(uid int64) (bool, error) {
+	sqlStr := `select role from user where user_id = ? and status = ?`
+
+	var role int8
+	err := db.Get(&role, sqlStr, uid, StatusNormal)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return role == RoleAdmin, err
+}
+
+// IsActive 判断用户存在并且没有注销
+func IsActive(uid int64) (bool, error) {
+	sqlStr := `select count(1) from user where user_id = ? and status = ?`
+
+	var count int
+	err := db.Get(&count, sqlStr, uid, StatusNormal)
+	return count > 0, err
+}
+
+// getProfile 查询没有注销的用户的资料
+func getProfile(uid int64) (*Profile, error) {
+	sqlStr := `select user_id, username, email, gender, avatar, bio, create_time
+from user
+where user_id = ? and status = ?`
+
+	p := &Profile{}
+	err := db.Get(p, sqlStr, uid, StatusNormal)
+	return p, err
+}
+
+// updateProfile 修改头像、简介和性别
+func updateProfile(p *Profile) error {
+	sqlStr := `update user set avatar = ?, bio = ?, gender = ? where user_id = ?`
+
+	_, err := db.Exec(sqlStr, p.Avatar, p.Bio, p.Gender, p.UserID)
+	return err
+}
+
+// getPassword 查询没有注销的用户的密码
+func getPassword(uid int64) (string, error) {
+	sqlStr := `select password from user where user_id = ? and status = ?`
+
+	var encoded string
+	err := db.Get(&encoded, sqlStr, uid, StatusNormal)
+	return encoded, err
+}
+
+// getTokenVersion 查询没有注销的用户当前的 token 版本
+func getTokenVersion(uid int64) (int64, error) {
+	sqlStr := `select token_version from user where user_id = ? and status = ?`
+
+	var version int64
+	err := db.Get(&version, sqlStr, uid, StatusNormal)
+	return version, err
+}
+
+// changePassword 修改密码并且让之前签发的 token 失效，encoded 是加密之后的密码
+func changePassword(uid int64, encoded string) error {
+	sqlStr := `update user set password = ?, token_version = token_version + 1 where user_id = ?`
+
+	_, err := db.Exec(sqlStr, encoded, uid)
+	return err
+}
+
+// updatePassword 修改密码，encoded 是加密之后的密码，登录时重新加密用，之前签发的 token 仍然有效
+func updatePassword(uid int64, encoded string) error {
+	sqlStr := `update user set password = ? where user_id = ?`
+
+	_, err := db.Exec(sqlStr, encoded, uid)
+	return err
+}
+
+// anonymizeUser 注销用户，清空个人信息，用户名和邮箱换成不会冲突的占位值
+// 同时退出所有社区和取消版主，帖子和评论保留
+func anonymizeUser(uid int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	placeholder := fmt.Sprintf("%s%d", deletedUsernamePrefix, uid)
+	sqlStr := `update user
+set username = ?, email = ?, password = '', gender = 0, avatar = '', bio = '', status = ?,
+    token_version = token_version + 1
+where user_id = ?`
+	if _, err = tx.Exec(sqlStr, placeholder, placeholder+deletedEmailSuffix, StatusDeleted, uid); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`delete from community_member where user_id = ?`, uid); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`delete from community_moderator where user_id = ?`, uid); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
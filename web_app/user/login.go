@@ -2,12 +2,16 @@ package user
 
 import (
 	"bookstore/web_app/code"
+	"bookstore/web_app/dao/redis"
 	"bookstore/web_app/pkg/jwt"
-	"bookstore/web_app/util"
+	"bookstore/web_app/pkg/password"
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 func Login(user User) (string, error) {
@@ -21,13 +25,67 @@ func Login(user User) (string, error) {
 	}
 
 	// 判断密码是否正确
-	password := util.EncryptPassword(user.Password)
-	if password != dbUser.Password {
-		return "", errors.New("password is not correct")
+	ok, needRehash, err := password.Verify(user.Password, dbUser.Password)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", code.ErrorInvalidPassword
+	}
+	// 旧的 MD5 密码或者加密参数变了，登录成功的时候重新加密，失败不影响登录
+	if needRehash {
+		if err = setPassword(dbUser.UserID, user.Password); err != nil {
+			zap.L().Warn("rehash password failed", zap.Int64("user_id", dbUser.UserID), zap.Error(err))
+		}
 	}
 
 	// 生成 JWT Token
-	return jwt.GenToken(dbUser.UserID, dbUser.Username)
+	return jwt.GenToken(dbUser.UserID, dbUser.Username, dbUser.TokenVersion)
+}
+
+// setPassword 用当前配置的算法加密密码并保存
+func setPassword(userID int64, plain string) error {
+	encoded, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	return updatePassword(userID, encoded)
+}
+
+// tokenVersionCacheExpiration token 版本的缓存时间
+// 删除缓存失败的时候，失效的 token 最多还能用这么久
+const tokenVersionCacheExpiration = 5 * time.Minute
+
+// CheckTokenVersion 检查 token 是不是在最近一次修改密码或者注销之后签发的
+// 用户已经注销或者 token 已经失效的时候返回 code.ErrorTokenRevoked
+func CheckTokenVersion(ctx context.Context, userID, version int64) error {
+	current, ok, err := redis.GetTokenVersion(ctx, userID)
+	if err != nil {
+		// 缓存出问题不影响查询
+		zap.L().Warn("redis.GetTokenVersion failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	if !ok {
+		current, err = getTokenVersion(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return code.ErrorTokenRevoked
+		}
+		if err != nil {
+			return err
+		}
+		if err = redis.SetTokenVersion(ctx, userID, current, tokenVersionCacheExpiration); err != nil {
+			zap.L().Warn("redis.SetTokenVersion failed", zap.Int64("user_id", userID), zap.Error(err))
+		}
+	}
+	if version != current {
+		return code.ErrorTokenRevoked
+	}
+	return nil
+}
+
+func delTokenVersionCache(ctx context.Context, userID int64) {
+	if err := redis.DelTokenVersion(ctx, userID); err != nil {
+		zap.L().Warn("redis.DelTokenVersion failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
 }
 
 // GetCurrentUserID 获取当前登录的用户 ID
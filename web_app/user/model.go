@@ -1,8 +1,47 @@
 package user
 
+import "time"
+
+// 用户角色
+const (
+	RoleUser int8 = iota
+	RoleAdmin
+)
+
+// 用户状态
+const (
+	StatusDeleted int8 = iota
+	StatusNormal
+)
+
+// 注销之后用户名换成前缀加上 user_id，邮箱再加上后缀，注册的时候不能使用
+const (
+	deletedUsernamePrefix = "deleted_"
+	deletedEmailSuffix    = "@deleted.invalid"
+)
+
 type User struct {
-	UserID   int64  `db:"user_id"`
-	Username string `db:"username"`
-	Password string `db:"password"`
-	Email    string `db:"email"`
+	UserID       int64  `db:"user_id"`
+	Username     string `db:"username"`
+	Password     string `db:"password"`
+	Email        string `db:"email"`
+	TokenVersion int64  `db:"token_version"`
+}
+
+// Profile 用户资料，Email 只有用户自己能看到
+type Profile struct {
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Username   string    `json:"username" db:"username"`
+	Email      string    `json:"email,omitempty" db:"email"`
+	Gender     int8      `json:"gender" db:"gender"`
+	Avatar     string    `json:"avatar" db:"avatar"`
+	Bio        string    `json:"bio" db:"bio"`
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
+
+// ProfileUpdate 修改用户资料，为 nil 的字段不修改
+type ProfileUpdate struct {
+	Avatar *string
+	Bio    *string
+	Gender *int8
 }
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"bookstore/web_app/code"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/pkg/jwt"
+	"bookstore/web_app/pkg/password"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// profileCacheExpiration 用户资料的缓存时间
+const profileCacheExpiration = time.Hour
+
+// GetProfile 查询用户资料，先查 redis 缓存，没有的话查 MySQL 并写回缓存
+// 用户不存在或者已经注销返回 code.ErrorUserNotExist
+func GetProfile(ctx context.Context, userID int64) (*Profile, error) {
+	data, ok, err := redis.GetUserProfile(ctx, userID)
+	if err != nil {
+		// 缓存出问题不影响查询
+		zap.L().Warn("redis.GetUserProfile failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	if ok {
+		p := &Profile{}
+		if err = json.Unmarshal(data, p); err == nil {
+			return p, nil
+		}
+		zap.L().Warn("unmarshal cached profile failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+
+	p, err := getProfile(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, code.ErrorUserNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(p); err == nil {
+		err = redis.SetUserProfile(ctx, userID, data, profileCacheExpiration)
+	}
+	if err != nil {
+		zap.L().Warn("redis.SetUserProfile failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return p, nil
+}
+
+// UpdateProfile 修改用户资料，返回修改之后的资料
+func UpdateProfile(ctx context.Context, userID int64, update ProfileUpdate) (*Profile, error) {
+	// 直接查 MySQL，避免用缓存里的旧数据覆盖
+	p, err := getProfile(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, code.ErrorUserNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Avatar != nil {
+		p.Avatar = *update.Avatar
+	}
+	if update.Bio != nil {
+		p.Bio = *update.Bio
+	}
+	if update.Gender != nil {
+		p.Gender = *update.Gender
+	}
+	if err = updateProfile(p); err != nil {
+		return nil, err
+	}
+	// 已经改成功了，缓存出问题不能再返回错误
+	if err = redis.DelUserProfile(ctx, userID); err != nil {
+		zap.L().Warn("redis.DelUserProfile failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return p, nil
+}
+
+// ChangePassword 修改密码，需要验证旧密码
+// 之前签发的 token 全部失效，返回一个新的 token
+func ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword string) (string, error) {
+	if err := checkPassword(userID, oldPassword); err != nil {
+		return "", err
+	}
+	encoded, err := password.Hash(newPassword)
+	if err != nil {
+		return "", err
+	}
+	if err = changePassword(userID, encoded); err != nil {
+		return "", err
+	}
+	delTokenVersionCache(ctx, userID)
+
+	u, err := GetUserById(userID)
+	if err != nil {
+		return "", err
+	}
+	return jwt.GenToken(u.UserID, u.Username, u.TokenVersion)
+}
+
+// DeleteAccount 注销账号，需要验证密码
+// 个人信息会被清空，发过的帖子和评论保留
+func DeleteAccount(ctx context.Context, userID int64, plain string) error {
+	if err := checkPassword(userID, plain); err != nil {
+		return err
+	}
+	if err := anonymizeUser(userID); err != nil {
+		return err
+	}
+
+	// 已经注销成功了，缓存出问题不能再返回错误
+	delTokenVersionCache(ctx, userID)
+	if err := redis.DelUserProfile(ctx, userID); err != nil {
+		zap.L().Warn("redis.DelUserProfile failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	if err := redis.DelUserCommunities(ctx, userID); err != nil {
+		zap.L().Warn("redis.DelUserCommunities failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return nil
+}
+
+// checkPassword 验证用户的密码
+func checkPassword(userID int64, plain string) error {
+	encoded, err := getPassword(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return code.ErrorUserNotExist
+	}
+	if err != nil {
+		return err
+	}
+	ok, _, err := password.Verify(plain, encoded)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return code.ErrorInvalidPassword
+	}
+	return nil
+}
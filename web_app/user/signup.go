@@ -1,10 +1,18 @@
 package user
 
 import (
+	"bookstore/web_app/code"
 	"bookstore/web_app/snowflake"
+	"strings"
 )
 
 func SignUp(u *User) error {
+	// 注销用户的占位用户名和邮箱是保留的，比较不区分大小写
+	if strings.HasPrefix(strings.ToLower(u.Username), deletedUsernamePrefix) ||
+		strings.HasSuffix(strings.ToLower(u.Email), deletedEmailSuffix) {
+		return code.ErrorUserExist
+	}
+
 	// 1. 判断用户存不存在
 	err := CheckUserExist(u.Username)
 	if err != nil {
@@ -0,0 +1,37 @@
+package user
+
+import (
+	"bookstore/web_app/code"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignUp_DeletedUsername(t *testing.T) {
+	testCases := []struct {
+		name     string
+		username string
+		email    string
+	}{
+		{
+			name:     "placeholder",
+			username: "deleted_123",
+		},
+		{
+			name:     "upper case",
+			username: "Deleted_123",
+		},
+		{
+			name:     "placeholder email",
+			username: "alice",
+			email:    "deleted_123@Deleted.invalid",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 注销用户的占位值不用查数据库就直接拒绝
+			err := SignUp(&User{Username: tc.username, Email: tc.email, Password: "12345678"})
+			assert.ErrorIs(t, err, code.ErrorUserExist)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package vote
+
+import "bookstore/web_app/dao/mysql"
+
+var db = mysql.GetDBConn()
+
+// insertVoteLog 保存投票记录，只用来审计，投票的状态以 redis 为准
+func insertVoteLog(v DBVote) error {
+	sqlStr := `insert into post_vote(post_id, user_id, direction) values (?, ?, ?)`
+
+	_, err := db.Exec(sqlStr, v.PostID, v.UserID, v.Direction)
+	return err
+}
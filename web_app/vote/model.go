@@ -0,0 +1,19 @@
+package vote
+
+import "time"
+
+// voteReq 投票的请求参数
+type voteReq struct {
+	PostID int64 `json:"post_id" binding:"required"`
+	// Direction 1 赞成，-1 反对，0 取消投票
+	Direction int8 `json:"direction" binding:"oneof=1 0 -1"`
+}
+
+// DBVote 投票记录
+type DBVote struct {
+	ID         int64     `json:"id" db:"id"`
+	PostID     int64     `json:"post_id" db:"post_id"`
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Direction  int8      `json:"direction" db:"direction"`
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
@@ -0,0 +1,74 @@
+package vote
+
+import (
+	"bookstore/web_app/code"
+	"bookstore/web_app/conf"
+	"bookstore/web_app/controller"
+	"bookstore/web_app/dao/redis"
+	"bookstore/web_app/logger"
+	"bookstore/web_app/user"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PostVoteHandler 为帖子投票
+func PostVoteHandler(ctx *gin.Context) {
+	// 1. 获取参数及参数的校验
+	req := voteReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.Ctx(ctx).Error("vote with invalid param", zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeInvalidParam)
+		return
+	}
+
+	userID, err := user.GetCurrentUserID(ctx)
+	if err != nil {
+		controller.ResponseError(ctx, controller.CodeNeedLogin)
+		return
+	}
+
+	// 2. 投票
+	var window time.Duration
+	if cfg := conf.Conf.VoteConfig; cfg != nil {
+		window = cfg.Window
+	}
+	changed, err := redis.VoteForPost(ctx.Request.Context(), userID, req.PostID, req.Direction, window)
+	switch {
+	case errors.Is(err, code.ErrorPostNotExist):
+		controller.ResponseError(ctx, controller.CodePostNotExist)
+		return
+	case errors.Is(err, code.ErrorVoteTimeExpire):
+		controller.ResponseError(ctx, controller.CodeVoteTimeExpire)
+		return
+	case errors.Is(err, code.ErrorVoteRepeated):
+		controller.ResponseError(ctx, controller.CodeVoteRepeated)
+		return
+	case err != nil:
+		logger.Ctx(ctx).Error("redis.VoteForPost failed",
+			zap.Int64("post_id", req.PostID),
+			zap.Int8("direction", req.Direction),
+			zap.Error(err))
+		controller.ResponseError(ctx, controller.CodeServerBusy)
+		return
+	}
+
+	// 投票没有变化，例如没投过票又取消，不需要记录
+	if !changed {
+		controller.ResponseSuccess(ctx, nil)
+		return
+	}
+
+	// 投票记录写失败不影响投票结果
+	err = insertVoteLog(DBVote{PostID: req.PostID, UserID: userID, Direction: req.Direction})
+	if err != nil {
+		logger.Ctx(ctx).Error("insertVoteLog failed",
+			zap.Int64("post_id", req.PostID),
+			zap.Error(err))
+	}
+
+	// 3. 返回响应
+	controller.ResponseSuccess(ctx, nil)
+}
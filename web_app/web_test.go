@@ -6,7 +6,10 @@ import (
 	"bookstore/web_app/dao/mysql"
 	"bookstore/web_app/dao/redis"
 	"bookstore/web_app/logger"
+	"bookstore/web_app/pkg/password"
+	"bookstore/web_app/post"
 	"bookstore/web_app/router"
+	"bookstore/web_app/search"
 	"bookstore/web_app/snowflake"
 	"context"
 	"fmt"
@@ -44,11 +47,26 @@ func TestWeb(t *testing.T) {
 	if err := snowflake.Init(conf.Conf.StartTime, conf.Conf.MachineID); err != nil {
 		fmt.Println("init snowflake failed, err:", err)
 	}
+	// 初始化密码加密的参数
+	if err := password.Init(conf.Conf.PasswordConfig); err != nil {
+		fmt.Println("init password failed, err:", err)
+		return
+	}
 	// 初始化 gin 框架内置的校验器使用的翻译器
 	if err := controller.InitTrans("zh"); err != nil {
 		fmt.Println("init validator trans failed, err:", err)
 		return
 	}
+	// 初始化搜索
+	if err := search.Init(context.Background(), conf.Conf.SearchConfig, mysql.GetDBConn()); err != nil {
+		fmt.Println("init search failed, err:", err)
+		return
+	}
+	// 把帖子列表上线之前发的帖子补到 redis 里
+	if err := post.BackfillPostList(context.Background()); err != nil {
+		fmt.Println("backfill post list failed, err:", err)
+		return
+	}
 	// 5. 注册路由
 	r := router.SetupRouter(conf.Conf.Mode)
 	// 6. 启动服务（优雅关机）